@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	v3 "github.com/rancher/go-rancher/v3"
 	"github.com/rancher/rancher-compose-executor/config"
+	rconvert "github.com/rancher/rancher-compose-executor/convert"
 	"github.com/rancher/rancher-compose-executor/yaml"
 	yml "gopkg.in/yaml.v2"
 )
@@ -443,6 +444,9 @@ func mergeRancherCompose(serviceConfig *config.ServiceConfig, service v3.Service
 	}
 	serviceConfig.Metadata = service.Metadata
 	delete(serviceConfig.Metadata, hashLabel)
+	delete(serviceConfig.Metadata, rconvert.TemplateScaleKey)
+	delete(serviceConfig.Metadata, rconvert.ConfigHashKey)
+	delete(serviceConfig.Metadata, rconvert.CanaryKey)
 	serviceConfig.RetainIp = launchConfig.RetainIp
 	serviceConfig.NetworkDriver = service.NetworkDriver
 	serviceConfig.StorageDriver = service.StorageDriver
@@ -458,6 +462,9 @@ func mergeRancherComposeStandalone(serviceConfig *config.ServiceConfig, containe
 	serviceConfig.HealthCheck = container.HealthCheck
 	serviceConfig.Metadata = container.Metadata
 	delete(serviceConfig.Metadata, hashLabel)
+	delete(serviceConfig.Metadata, rconvert.TemplateScaleKey)
+	delete(serviceConfig.Metadata, rconvert.ConfigHashKey)
+	delete(serviceConfig.Metadata, rconvert.CanaryKey)
 	serviceConfig.RetainIp = container.RetainIp
 	serviceConfig.MilliCpuReservation = yaml.StringorInt(container.MilliCpuReservation)
 }
@@ -573,6 +580,9 @@ func convertScale(serviceConfig *config.ServiceConfig, service v3.Service, launc
 			serviceConfig.ScaleIncrement = yaml.StringorInt(service.ScaleIncrement)
 		} else {
 			serviceConfig.Scale = yaml.StringorInt(service.Scale)
+			serviceConfig.ScaleMin = yaml.StringorInt(service.ScaleMin)
+			serviceConfig.ScaleMax = yaml.StringorInt(service.ScaleMax)
+			serviceConfig.ScaleIncrement = yaml.StringorInt(service.ScaleIncrement)
 		}
 	}
 }
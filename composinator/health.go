@@ -0,0 +1,59 @@
+package composinator
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/rancher/rancher-compose-executor/redact"
+)
+
+// Check returns why the executor isn't ready, or nil
+type Check func() error
+
+var (
+	checksLock      sync.Mutex
+	readinessChecks = map[string]Check{}
+)
+
+// AddReadinessCheck adds a check to those run on every request of /readyz
+func AddReadinessCheck(name string, check Check) {
+	checksLock.Lock()
+	defer checksLock.Unlock()
+	readinessChecks[name] = check
+}
+
+// healthz answers as long as the process serves requests
+func healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyz answers with the result of every readiness check, and fails if one
+// of them does
+func readyz(w http.ResponseWriter, r *http.Request) {
+	checksLock.Lock()
+	names := make([]string, 0, len(readinessChecks))
+	checks := make(map[string]Check, len(readinessChecks))
+	for name, check := range readinessChecks {
+		names = append(names, name)
+		checks[name] = check
+	}
+	checksLock.Unlock()
+	sort.Strings(names)
+
+	status := http.StatusOK
+	results := ""
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			status = http.StatusServiceUnavailable
+			results += fmt.Sprintf("%s: %s\n", name, redact.String(err.Error()))
+		} else {
+			results += fmt.Sprintf("%s: ok\n", name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, results)
+}
@@ -1,23 +1,35 @@
 package composinator
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"fmt"
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 	v3 "github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/metrics"
 )
 
 const (
 	cattleExportListenPort = "CATTLE_EXPORT_LISTEN_PORT"
+	// healthListenAddress is the address health checks are served on too,
+	// for probes from outside of the host
+	healthListenAddress = "HEALTH_LISTEN_ADDRESS"
 )
 
-var rancherClient *v3.RancherClient
+var (
+	rancherClient *v3.RancherClient
+
+	serverLock sync.Mutex
+	servers    []*http.Server
+)
 
 type convertOptions struct {
 	StackID string `json:"stackId,omitempty" yaml:"stackId,omitempty"`
@@ -64,10 +76,74 @@ func StartServer() error {
 	rancherClient = rc
 	router := mux.NewRouter()
 	router.HandleFunc("/convert", handler).Methods("POST")
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	router.HandleFunc("/healthz", healthz).Methods("GET")
+	router.HandleFunc("/readyz", readyz).Methods("GET")
 	listenPort := os.Getenv(cattleExportListenPort)
 	if listenPort == "" {
 		listenPort = "8099"
 	}
 	logrus.Infof("starting composinator on %v", listenPort)
-	return http.ListenAndServe(fmt.Sprintf("127.0.0.1:%s", listenPort), router)
+	s := &http.Server{
+		Addr:    fmt.Sprintf("127.0.0.1:%s", listenPort),
+		Handler: router,
+	}
+
+	if address := os.Getenv(healthListenAddress); address != "" {
+		if _, err := startHealthServer(address); err != nil {
+			return err
+		}
+	}
+
+	serverLock.Lock()
+	servers = append(servers, s)
+	serverLock.Unlock()
+	if err := s.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// startHealthServer serves the health checks alone on the address, and
+// returns the address it listens on
+func startHealthServer(address string) (net.Addr, error) {
+	router := mux.NewRouter()
+	router.HandleFunc("/healthz", healthz).Methods("GET")
+	router.HandleFunc("/readyz", readyz).Methods("GET")
+
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("serving health checks on %v", l.Addr())
+	s := &http.Server{
+		Handler: router,
+	}
+	serverLock.Lock()
+	servers = append(servers, s)
+	serverLock.Unlock()
+
+	go func() {
+		if err := s.Serve(l); err != http.ErrServerClosed {
+			logrus.Errorf("Failed to serve health checks: %v", err)
+		}
+	}()
+	return l.Addr(), nil
+}
+
+// StopServer stops the servers once the requests being served are done, or
+// ctx is
+func StopServer(ctx context.Context) error {
+	serverLock.Lock()
+	stopping := servers
+	servers = nil
+	serverLock.Unlock()
+
+	var result error
+	for _, s := range stopping {
+		if err := s.Shutdown(ctx); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
 }
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"github.com/rancher/rancher-compose-executor/yaml"
+)
+
+// Healthcheck holds a docker-compose healthcheck
+type Healthcheck struct {
+	Test        HealthcheckTest  `yaml:"test,omitempty"`
+	Interval    string           `yaml:"interval,omitempty"`
+	Timeout     string           `yaml:"timeout,omitempty"`
+	Retries     yaml.StringorInt `yaml:"retries,omitempty"`
+	StartPeriod string           `yaml:"start_period,omitempty"`
+	Disable     bool             `yaml:"disable,omitempty"`
+}
+
+// HealthcheckTest holds a healthcheck test in its list form, a test given as
+// a plain string is run with CMD-SHELL
+type HealthcheckTest []string
+
+func (h *HealthcheckTest) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var stringType string
+	if err := unmarshal(&stringType); err == nil {
+		*h = []string{"CMD-SHELL", stringType}
+		return nil
+	}
+
+	var sliceType []string
+	if err := unmarshal(&sliceType); err != nil {
+		return err
+	}
+
+	*h = sliceType
+	return nil
+}
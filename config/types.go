@@ -93,11 +93,11 @@ type ServiceConfigV1 struct {
 	//Hostname    string                      `yaml:"hostname,omitempty"`
 	HealthCheck *client.InstanceHealthCheck `yaml:"health_check,omitempty"`
 
-	Metadata        map[string]interface{}          `yaml:"metadata,omitempty"`
-	ServiceSchemas  map[string]client.Schema        `yaml:"service_schemas,omitempty"`
-	UpgradeStrategy client.InServiceUpgradeStrategy `yaml:"upgrade_strategy,omitempty"`
-	StorageDriver   *client.StorageDriver           `yaml:"storage_driver,omitempty"`
-	NetworkDriver   *client.NetworkDriver           `yaml:"network_driver,omitempty"`
+	Metadata        map[string]interface{}   `yaml:"metadata,omitempty"`
+	ServiceSchemas  map[string]client.Schema `yaml:"service_schemas,omitempty"`
+	UpgradeStrategy UpgradeStrategy          `yaml:"upgrade_strategy,omitempty"`
+	StorageDriver   *client.StorageDriver    `yaml:"storage_driver,omitempty"`
+	NetworkDriver   *client.NetworkDriver    `yaml:"network_driver,omitempty"`
 }
 
 // Log holds v2 logging information
@@ -138,6 +138,7 @@ type ServiceConfig struct {
 	ExternalLinks     []string             `yaml:"external_links,omitempty"`
 	ExtraHosts        []string             `yaml:"extra_hosts,omitempty"`
 	GroupAdd          []string             `yaml:"group_add,omitempty"`
+	Healthcheck       *Healthcheck         `yaml:"healthcheck,omitempty"`
 	Image             string               `yaml:"image,omitempty"`
 	Init              bool                 `yaml:"init,omitempty"`
 	Isolation         string               `yaml:"isolation,omitempty"`
@@ -200,14 +201,16 @@ type RancherConfig struct {
 	ScaleMin            yaml.StringorInt `yaml:"scale_min,omitempty"`
 	ScaleMax            yaml.StringorInt `yaml:"scale_max,omitempty"`
 	ScaleIncrement      yaml.StringorInt `yaml:"scale_increment,omitempty"`
+	RetainScale         bool             `yaml:"retain_scale,omitempty"`
+	FinishUpgrade       string           `yaml:"finish_upgrade,omitempty"`
 	StartOnCreate       bool             `yaml:"start_on_create,omitempty"`
 	MilliCpuReservation yaml.StringorInt `yaml:"milli_cpu_reservation,omitempty"`
 
-	Metadata        map[string]interface{}          `yaml:"metadata,omitempty"`
-	NetworkDriver   *client.NetworkDriver           `yaml:"network_driver,omitempty"`
-	ServiceSchemas  map[string]client.Schema        `yaml:"service_schemas,omitempty"`
-	StorageDriver   *client.StorageDriver           `yaml:"storage_driver,omitempty"`
-	UpgradeStrategy client.InServiceUpgradeStrategy `yaml:"upgrade_strategy,omitempty"`
+	Metadata        map[string]interface{}   `yaml:"metadata,omitempty"`
+	NetworkDriver   *client.NetworkDriver    `yaml:"network_driver,omitempty"`
+	ServiceSchemas  map[string]client.Schema `yaml:"service_schemas,omitempty"`
+	StorageDriver   *client.StorageDriver    `yaml:"storage_driver,omitempty"`
+	UpgradeStrategy UpgradeStrategy          `yaml:"upgrade_strategy,omitempty"`
 }
 
 // TODO: json tags needed?
@@ -277,6 +280,15 @@ type SecretConfig struct {
 	External string `yaml:"external,omitempty"`
 }
 
+// CertificateConfig holds a TLS certificate of the stack. Each field is
+// either a file read through the resource lookup or, when it starts with
+// "-----BEGIN", the PEM content itself, typically from an answer.
+type CertificateConfig struct {
+	Cert  string `yaml:"cert,omitempty"`
+	Key   string `yaml:"key,omitempty"`
+	Chain string `yaml:"chain,omitempty"`
+}
+
 type HostConfig struct {
 	Count    int    `yaml:"count,omitempty"`
 	Template string `yaml:"template,omitempty"`
@@ -292,7 +304,8 @@ type DependencyConfig struct {
 }
 
 type RawConfig struct {
-	Version string `yaml:"version,omitempty"`
+	Version       string `yaml:"version,omitempty"`
+	FinishUpgrade string `yaml:"finish_upgrade,omitempty"`
 
 	Services         RawServiceMap `yaml:"services,omitempty"`
 	Containers       RawServiceMap `yaml:"containers,omitempty"`
@@ -307,22 +320,26 @@ type RawConfig struct {
 	Volumes      map[string]interface{} `yaml:"volumes,omitempty"`
 	Networks     map[string]interface{} `yaml:"networks,omitempty"`
 	Secrets      map[string]interface{} `yaml:"secrets,omitempty"`
+	Configs      map[string]interface{} `yaml:"configs,omitempty"`
+	Certificates map[string]interface{} `yaml:"certificates,omitempty"`
 	Hosts        map[string]interface{} `yaml:"hosts,omitempty"`
 
 	KubernetesResources map[string]interface{} `yaml:"kubernetes_resources,omitempty"`
 }
 
 type Config struct {
-	Version             string                       `yaml:"version,omitempty"`
-	Services            map[string]*ServiceConfig    `yaml:"services,omitempty"`
-	Containers          map[string]*ServiceConfig    `yaml:"containers,omitempty"`
-	Dependencies        map[string]*DependencyConfig `yaml:"dependencies,omitempty"`
-	Volumes             map[string]*VolumeConfig     `yaml:"volumes,omitempty"`
-	Networks            map[string]*NetworkConfig    `yaml:"networks,omitempty"`
-	Secrets             map[string]*SecretConfig     `yaml:"secrets,omitempty"`
-	Hosts               map[string]*HostConfig       `yaml:"hosts,omitempty"`
-	KubernetesResources map[string]interface{}       `yaml:"kubernetes_resources,omitempty"`
-	SidekickInfo        *SidekickInfo                `yaml:"-"`
+	Version             string                        `yaml:"version,omitempty"`
+	FinishUpgrade       string                        `yaml:"finish_upgrade,omitempty"`
+	Services            map[string]*ServiceConfig     `yaml:"services,omitempty"`
+	Containers          map[string]*ServiceConfig     `yaml:"containers,omitempty"`
+	Dependencies        map[string]*DependencyConfig  `yaml:"dependencies,omitempty"`
+	Volumes             map[string]*VolumeConfig      `yaml:"volumes,omitempty"`
+	Networks            map[string]*NetworkConfig     `yaml:"networks,omitempty"`
+	Secrets             map[string]*SecretConfig      `yaml:"secrets,omitempty"`
+	Certificates        map[string]*CertificateConfig `yaml:"certificates,omitempty"`
+	Hosts               map[string]*HostConfig        `yaml:"hosts,omitempty"`
+	KubernetesResources map[string]interface{}        `yaml:"kubernetes_resources,omitempty"`
+	SidekickInfo        *SidekickInfo                 `yaml:"-"`
 }
 
 func NewConfig() *Config {
@@ -333,6 +350,7 @@ func NewConfig() *Config {
 		Volumes:             map[string]*VolumeConfig{},
 		Networks:            map[string]*NetworkConfig{},
 		Secrets:             map[string]*SecretConfig{},
+		Certificates:        map[string]*CertificateConfig{},
 		Hosts:               map[string]*HostConfig{},
 		KubernetesResources: map[string]interface{}{},
 	}
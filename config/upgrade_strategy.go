@@ -0,0 +1,44 @@
+package config
+
+import "github.com/rancher/go-rancher/v3"
+
+const (
+	InServiceStrategy = "in_service"
+	CanaryStrategy    = "canary"
+	BlueGreenStrategy = "blue_green"
+
+	FinishUpgradeAuto   = "auto"
+	FinishUpgradeManual = "manual"
+)
+
+// UpgradeStrategy holds the upgrade_strategy of a service
+type UpgradeStrategy struct {
+	client.InServiceUpgradeStrategy `yaml:",inline"`
+
+	// RollbackOnFailure rolls back every service upgraded by the same stack
+	// update when this service fails to become healthy after its upgrade
+	RollbackOnFailure bool `yaml:"rollback_on_failure,omitempty"`
+	// HealthTimeoutMillis is how long the service has to become healthy
+	// after its upgrade before it is considered failed
+	HealthTimeoutMillis int64 `yaml:"health_timeout_millis,omitempty"`
+
+	// Strategy is one of in_service (the default), canary or blue_green
+	Strategy string `yaml:"strategy,omitempty"`
+	// CanaryInstances is how many instances a canary upgrade starts with
+	CanaryInstances int64 `yaml:"canary_instances,omitempty"`
+	// CanaryPauseMillis is how long a healthy canary runs before the
+	// upgrade continues
+	CanaryPauseMillis int64 `yaml:"canary_pause_millis,omitempty"`
+	// CanaryConfirm leaves a healthy canary paused until the upgrade of the
+	// stack is finished
+	CanaryConfirm bool `yaml:"canary_confirm,omitempty"`
+}
+
+// ManualFinishUpgrade returns whether upgrades of the service are left for
+// the operator to finish. The service setting overrides the stack setting.
+func (c *Config) ManualFinishUpgrade(name string) bool {
+	if serviceConfig, ok := c.Services[name]; ok && serviceConfig.FinishUpgrade != "" {
+		return serviceConfig.FinishUpgrade == FinishUpgradeManual
+	}
+	return c.FinishUpgrade == FinishUpgradeManual
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/rancher/rancher-compose-executor/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeStrategy(t *testing.T) {
+	var serviceConfig ServiceConfig
+	err := utils.Convert(map[interface{}]interface{}{
+		"upgrade_strategy": map[interface{}]interface{}{
+			"batch_size":            2,
+			"start_first":           true,
+			"rollback_on_failure":   true,
+			"health_timeout_millis": 60000,
+		},
+	}, &serviceConfig)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, 2, serviceConfig.UpgradeStrategy.BatchSize)
+	assert.True(t, serviceConfig.UpgradeStrategy.StartFirst)
+	assert.True(t, serviceConfig.UpgradeStrategy.RollbackOnFailure)
+	assert.EqualValues(t, 60000, serviceConfig.UpgradeStrategy.HealthTimeoutMillis)
+}
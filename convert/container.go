@@ -4,12 +4,13 @@ import (
 	"github.com/rancher/go-rancher/v3"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
 )
 
-func Container(p *project.Project, name string) (*client.Container, error) {
+func Container(ctx context.Context, p *project.Project, name string) (*client.Container, error) {
 	var err error
 
-	launchConfig, _, err := createLaunchConfigs(p, name)
+	launchConfig, _, err := createLaunchConfigs(ctx, p, name)
 	if err != nil {
 		return nil, err
 	}
@@ -44,8 +45,8 @@ func Container(p *project.Project, name string) (*client.Container, error) {
 	return &container, nil
 }
 
-func ContainerConfig(p *project.Project, name string) (*client.ContainerConfig, error) {
-	container, err := Container(p, name)
+func ContainerConfig(ctx context.Context, p *project.Project, name string) (*client.ContainerConfig, error) {
+	container, err := Container(ctx, p, name)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,141 @@
+package convert
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"golang.org/x/net/context"
+)
+
+// populateHealthcheck translates a docker-compose healthcheck into the launch
+// config. HTTP probes of the container itself become Rancher HTTP checks, any
+// other test is run as a command health check.
+func populateHealthcheck(ctx context.Context, name string, healthcheck *config.Healthcheck, launchConfig *client.LaunchConfig) error {
+	if healthcheck == nil || healthcheck.Disable {
+		return nil
+	}
+
+	logger := logging.FromContext(ctx).WithField(logging.Service, name)
+
+	if launchConfig.HealthCheck != nil {
+		logger.Warnf("Service %s defines both health_check and healthcheck, ignoring healthcheck", name)
+		return nil
+	}
+
+	test := []string(healthcheck.Test)
+	if len(test) == 0 || test[0] == "NONE" {
+		if len(test) == 0 {
+			logger.Warnf("Service %s healthcheck has no test, ignoring healthcheck", name)
+		}
+		return nil
+	}
+
+	interval, err := durationToMillis(healthcheck.Interval)
+	if err != nil {
+		return err
+	}
+	timeout, err := durationToMillis(healthcheck.Timeout)
+	if err != nil {
+		return err
+	}
+	startPeriod, err := durationToMillis(healthcheck.StartPeriod)
+	if err != nil {
+		return err
+	}
+
+	if port, requestLine, ok := parseHTTPCheck(healthcheckCommand(test)); ok {
+		launchConfig.HealthCheck = &client.InstanceHealthCheck{
+			Port:                port,
+			RequestLine:         requestLine,
+			Interval:            interval,
+			ResponseTimeout:     timeout,
+			InitializingTimeout: startPeriod,
+			UnhealthyThreshold:  int64(healthcheck.Retries),
+		}
+		return nil
+	}
+
+	switch test[0] {
+	case "CMD", "CMD-SHELL":
+	default:
+		logger.Warnf("Service %s healthcheck test %q can not be translated, it must start with NONE, CMD or CMD-SHELL", name, strings.Join(test, " "))
+		return nil
+	}
+
+	if startPeriod != 0 {
+		logger.Warnf("Service %s healthcheck start_period is not supported for command health checks, ignoring it", name)
+	}
+
+	launchConfig.HealthCmd = test
+	launchConfig.HealthInterval = interval
+	launchConfig.HealthTimeout = timeout
+	launchConfig.HealthRetries = int64(healthcheck.Retries)
+
+	return nil
+}
+
+// healthcheckCommand returns the command of a healthcheck test without the
+// CMD/CMD-SHELL prefix
+func healthcheckCommand(test []string) []string {
+	switch test[0] {
+	case "CMD":
+		return test[1:]
+	case "CMD-SHELL":
+		return strings.Fields(strings.Join(test[1:], " "))
+	}
+	return nil
+}
+
+// parseHTTPCheck recognizes curl and wget probes of a local URL and returns
+// the port and request line of the equivalent HTTP check
+func parseHTTPCheck(command []string) (int64, string, bool) {
+	if len(command) == 0 || (command[0] != "curl" && command[0] != "wget") {
+		return 0, "", false
+	}
+
+	for _, arg := range command[1:] {
+		arg = strings.Trim(arg, `"'`)
+		if !strings.HasPrefix(arg, "http://") {
+			continue
+		}
+
+		parsed, err := url.Parse(arg)
+		if err != nil {
+			return 0, "", false
+		}
+
+		host := parsed.Hostname()
+		if host != "localhost" && host != "127.0.0.1" && host != "0.0.0.0" {
+			return 0, "", false
+		}
+
+		port := int64(80)
+		if parsed.Port() != "" {
+			port, err = strconv.ParseInt(parsed.Port(), 10, 64)
+			if err != nil {
+				return 0, "", false
+			}
+		}
+
+		return port, fmt.Sprintf(`GET "%s" "HTTP/1.0"`, parsed.RequestURI()), true
+	}
+
+	return 0, "", false
+}
+
+func durationToMillis(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration %s: %v", value, err)
+	}
+	return int64(duration / time.Millisecond), nil
+}
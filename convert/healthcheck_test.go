@@ -0,0 +1,88 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestPopulateHealthcheckHTTP(t *testing.T) {
+	for _, test := range []config.HealthcheckTest{
+		{"CMD", "curl", "-f", "http://localhost:8080/ping?full=1"},
+		{"CMD-SHELL", "curl -f http://localhost:8080/ping?full=1 || exit 1"},
+		{"CMD", "wget", "-q", "-O", "-", "http://127.0.0.1:8080/ping?full=1"},
+	} {
+		var launchConfig client.LaunchConfig
+		err := populateHealthcheck(context.Background(), "web", &config.Healthcheck{
+			Test:        test,
+			Interval:    "5s",
+			Timeout:     "2s",
+			Retries:     3,
+			StartPeriod: "1m",
+		}, &launchConfig)
+		assert.NoError(t, err)
+		assert.Nil(t, launchConfig.HealthCmd)
+		assert.Equal(t, &client.InstanceHealthCheck{
+			Port:                8080,
+			RequestLine:         `GET "/ping?full=1" "HTTP/1.0"`,
+			Interval:            5000,
+			ResponseTimeout:     2000,
+			InitializingTimeout: 60000,
+			UnhealthyThreshold:  3,
+		}, launchConfig.HealthCheck)
+	}
+}
+
+func TestPopulateHealthcheckCommand(t *testing.T) {
+	var launchConfig client.LaunchConfig
+	err := populateHealthcheck(context.Background(), "db", &config.Healthcheck{
+		Test:     config.HealthcheckTest{"CMD-SHELL", "pg_isready"},
+		Interval: "10s",
+		Retries:  5,
+	}, &launchConfig)
+	assert.NoError(t, err)
+	assert.Nil(t, launchConfig.HealthCheck)
+	assert.Equal(t, []string{"CMD-SHELL", "pg_isready"}, launchConfig.HealthCmd)
+	assert.EqualValues(t, 10000, launchConfig.HealthInterval)
+	assert.EqualValues(t, 5, launchConfig.HealthRetries)
+
+	// A remote URL can not be checked by Rancher, so it stays a command
+	launchConfig = client.LaunchConfig{}
+	err = populateHealthcheck(context.Background(), "web", &config.Healthcheck{
+		Test: config.HealthcheckTest{"CMD", "curl", "-f", "http://example.com/"},
+	}, &launchConfig)
+	assert.NoError(t, err)
+	assert.Nil(t, launchConfig.HealthCheck)
+	assert.Equal(t, []string{"CMD", "curl", "-f", "http://example.com/"}, launchConfig.HealthCmd)
+}
+
+func TestPopulateHealthcheckSkipped(t *testing.T) {
+	existing := &client.InstanceHealthCheck{Port: 80}
+	launchConfig := client.LaunchConfig{HealthCheck: existing}
+	err := populateHealthcheck(context.Background(), "web", &config.Healthcheck{
+		Test: config.HealthcheckTest{"CMD", "curl", "http://localhost:8080/"},
+	}, &launchConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, existing, launchConfig.HealthCheck)
+
+	launchConfig = client.LaunchConfig{}
+	for _, healthcheck := range []*config.Healthcheck{
+		nil,
+		{Disable: true, Test: config.HealthcheckTest{"CMD", "true"}},
+		{Test: config.HealthcheckTest{"NONE"}},
+		{Test: config.HealthcheckTest{"true"}},
+	} {
+		assert.NoError(t, populateHealthcheck(context.Background(), "web", healthcheck, &launchConfig))
+		assert.Nil(t, launchConfig.HealthCheck)
+		assert.Nil(t, launchConfig.HealthCmd)
+	}
+
+	err = populateHealthcheck(context.Background(), "web", &config.Healthcheck{
+		Test:     config.HealthcheckTest{"CMD", "true"},
+		Interval: "often",
+	}, &launchConfig)
+	assert.Error(t, err)
+}
@@ -9,19 +9,24 @@ import (
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/utils"
 	"github.com/rancher/rancher-compose-executor/yaml"
+	"golang.org/x/net/context"
 )
 
 const (
 	LegacyLBImage = "rancher/load-balancer-service"
+
+	// defaultLBImage replaces the legacy load balancer image when there is
+	// no server to look the lb.instance.image setting up on
+	defaultLBImage = "rancher/lb-service-haproxy"
 )
 
-func createLaunchConfigs(project *project.Project, name string) (client.LaunchConfig, []client.LaunchConfig, error) {
+func createLaunchConfigs(ctx context.Context, project *project.Project, name string) (client.LaunchConfig, []client.LaunchConfig, error) {
 	serviceConfig, ok := project.Config.Services[name]
 	if !ok {
 		return client.LaunchConfig{}, nil, fmt.Errorf("Failed to find service config for %s", name)
 	}
 	secondaryLaunchConfigs := []client.LaunchConfig{}
-	launchConfig, err := createLaunchConfig(project, *serviceConfig)
+	launchConfig, err := createLaunchConfig(ctx, project, name, *serviceConfig)
 	if err != nil {
 		return launchConfig, nil, err
 	}
@@ -33,7 +38,7 @@ func createLaunchConfigs(project *project.Project, name string) (client.LaunchCo
 				return launchConfig, nil, fmt.Errorf("Failed to find sidekick: %s", secondaryName)
 			}
 
-			launchConfig, err := createLaunchConfig(project, *serviceConfig)
+			launchConfig, err := createLaunchConfig(ctx, project, secondaryName, *serviceConfig)
 			if err != nil {
 				return launchConfig, nil, err
 			}
@@ -52,15 +57,14 @@ func createLaunchConfigs(project *project.Project, name string) (client.LaunchCo
 	return launchConfig, secondaryLaunchConfigs, nil
 }
 
-func createLaunchConfig(p *project.Project, serviceConfig config.ServiceConfig) (client.LaunchConfig, error) {
+func createLaunchConfig(ctx context.Context, p *project.Project, name string, serviceConfig config.ServiceConfig) (client.LaunchConfig, error) {
 	newLabels := yaml.SliceorMap{}
-	if serviceConfig.Image == "rancher/load-balancer-service" {
-		// Lookup default load balancer image
-		lbImageSetting, err := p.Client.Setting.ById("lb.instance.image")
+	if serviceConfig.Image == LegacyLBImage {
+		image, err := lbImage(p)
 		if err != nil {
 			return client.LaunchConfig{}, err
 		}
-		serviceConfig.Image = lbImageSetting.Value
+		serviceConfig.Image = image
 
 		// Strip off legacy load balancer labels
 		for k, v := range serviceConfig.Labels {
@@ -76,7 +80,11 @@ func createLaunchConfig(p *project.Project, serviceConfig config.ServiceConfig)
 		return result, err
 	}
 
-	result.Secrets, err = setupSecrets(p.Client, serviceConfig)
+	if err := populateHealthcheck(ctx, name, serviceConfig.Healthcheck, &result); err != nil {
+		return result, err
+	}
+
+	result.Secrets, err = setupSecrets(p, serviceConfig)
 	if err != nil {
 		return result, err
 	}
@@ -90,7 +98,14 @@ func modifyLbImage(p *project.Project, image string) (string, error) {
 		return image, nil
 	}
 
-	// Lookup default load balancer image
+	return lbImage(p)
+}
+
+// lbImage looks the default load balancer image up on the server
+func lbImage(p *project.Project) (string, error) {
+	if p.Client == nil {
+		return defaultLBImage, nil
+	}
 	lbImageSetting, err := p.Client.Setting.ById("lb.instance.image")
 	if err != nil {
 		return "", err
@@ -98,22 +113,18 @@ func modifyLbImage(p *project.Project, image string) (string, error) {
 	return lbImageSetting.Value, nil
 }
 
-func setupSecrets(c *client.RancherClient, serviceConfig config.ServiceConfig) ([]client.SecretReference, error) {
+func setupSecrets(p *project.Project, serviceConfig config.ServiceConfig) ([]client.SecretReference, error) {
 	var result []client.SecretReference
 	for _, secret := range serviceConfig.Secrets {
-		existingSecrets, err := c.Secret.List(&client.ListOpts{
-			Filters: map[string]interface{}{
-				"name": secret.Source,
-			},
-		})
+		existing, err := p.ServerResourceLookup.Secret(secret.Source)
 		if err != nil {
 			return nil, err
 		}
-		if len(existingSecrets.Data) == 0 {
+		if existing == nil {
 			return nil, fmt.Errorf("Failed to find secret %s", secret.Source)
 		}
 		result = append(result, client.SecretReference{
-			SecretId: existingSecrets.Data[0].Id,
+			SecretId: existing.Id,
 			Name:     secret.Target,
 			Uid:      secret.Uid,
 			Gid:      secret.Gid,
@@ -0,0 +1,11 @@
+package convert
+
+const (
+	// ConfigHashKey is the service metadata key holding the hash of the
+	// launch configs a blue/green service was deployed with
+	ConfigHashKey = "io.rancher.service.config_hash"
+
+	// CanaryKey is the service metadata key marking a service paused by a
+	// canary upgrade, which the next stack update resumes
+	CanaryKey = "io.rancher.service.canary"
+)
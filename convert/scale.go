@@ -0,0 +1,68 @@
+package convert
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+)
+
+// TemplateScaleKey is the service metadata key holding the scale of the
+// template the service was last deployed with
+const TemplateScaleKey = "io.rancher.service.template_scale"
+
+// populateScale sets the scale and scale policy of the service, validating
+// that the scale is within scale_min and scale_max
+func populateScale(name string, serviceConfig *config.ServiceConfig, service *client.Service) error {
+	scale := int64(serviceConfig.Scale)
+	scaleMin := int64(serviceConfig.ScaleMin)
+	scaleMax := int64(serviceConfig.ScaleMax)
+
+	if scaleMin < 0 || scaleMax < 0 || serviceConfig.ScaleIncrement < 0 {
+		return fmt.Errorf("Service %s: scale_min, scale_max and scale_increment must not be negative", name)
+	}
+	if scaleMax > 0 && scaleMin > scaleMax {
+		return fmt.Errorf("Service %s: scale_min %d is greater than scale_max %d", name, scaleMin, scaleMax)
+	}
+	if scale == 0 {
+		scale = max(1, scaleMin)
+	}
+	if scale < scaleMin {
+		return fmt.Errorf("Service %s: scale %d is less than scale_min %d", name, scale, scaleMin)
+	}
+	if scaleMax > 0 && scale > scaleMax {
+		return fmt.Errorf("Service %s: scale %d is greater than scale_max %d", name, scale, scaleMax)
+	}
+
+	service.Scale = scale
+	service.ScaleMin = scaleMin
+	service.ScaleMax = scaleMax
+	service.ScaleIncrement = int64(serviceConfig.ScaleIncrement)
+
+	if service.Metadata == nil {
+		service.Metadata = map[string]interface{}{}
+	}
+	service.Metadata[TemplateScaleKey] = strconv.FormatInt(scale, 10)
+
+	return nil
+}
+
+// RetainScale keeps the live scale of an existing service, which an operator
+// or autoscaler may have changed, unless the scale in the template changed
+// since the service was last deployed
+func RetainScale(existing *client.Service, updates *client.Service) {
+	deployed, ok := existing.Metadata[TemplateScaleKey]
+	if !ok || fmt.Sprint(deployed) != strconv.FormatInt(updates.Scale, 10) {
+		return
+	}
+
+	scale := existing.Scale
+	if scale < updates.ScaleMin {
+		scale = updates.ScaleMin
+	}
+	if updates.ScaleMax > 0 && scale > updates.ScaleMax {
+		scale = updates.ScaleMax
+	}
+	updates.Scale = scale
+}
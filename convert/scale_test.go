@@ -0,0 +1,82 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPopulateScale(t *testing.T) {
+	service := &client.Service{}
+	err := populateScale("web", &config.ServiceConfig{
+		RancherConfig: config.RancherConfig{
+			Scale:          3,
+			ScaleMin:       2,
+			ScaleMax:       6,
+			ScaleIncrement: 2,
+		},
+	}, service)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, service.Scale)
+	assert.EqualValues(t, 2, service.ScaleMin)
+	assert.EqualValues(t, 6, service.ScaleMax)
+	assert.EqualValues(t, 2, service.ScaleIncrement)
+	assert.Equal(t, "3", service.Metadata[TemplateScaleKey])
+
+	service = &client.Service{}
+	assert.NoError(t, populateScale("web", &config.ServiceConfig{
+		RancherConfig: config.RancherConfig{
+			ScaleMin: 2,
+		},
+	}, service))
+	assert.EqualValues(t, 2, service.Scale)
+
+	service = &client.Service{}
+	assert.NoError(t, populateScale("web", &config.ServiceConfig{}, service))
+	assert.EqualValues(t, 1, service.Scale)
+}
+
+func TestPopulateScaleInvalid(t *testing.T) {
+	for _, rancherConfig := range []config.RancherConfig{
+		{Scale: 1, ScaleMin: 2},
+		{Scale: 5, ScaleMax: 4},
+		{ScaleMin: 3, ScaleMax: 2},
+		{ScaleMin: -1},
+	} {
+		err := populateScale("web", &config.ServiceConfig{
+			RancherConfig: rancherConfig,
+		}, &client.Service{})
+		assert.Error(t, err, "%+v", rancherConfig)
+	}
+}
+
+func TestRetainScale(t *testing.T) {
+	existing := &client.Service{
+		Scale: 5,
+		Metadata: map[string]interface{}{
+			TemplateScaleKey: "2",
+		},
+	}
+
+	// Template scale unchanged, the live scale is kept
+	updates := &client.Service{Scale: 2}
+	RetainScale(existing, updates)
+	assert.EqualValues(t, 5, updates.Scale)
+
+	// but kept within the scale policy
+	updates = &client.Service{Scale: 2, ScaleMax: 4}
+	RetainScale(existing, updates)
+	assert.EqualValues(t, 4, updates.Scale)
+
+	// Template scale changed, the template wins
+	updates = &client.Service{Scale: 3}
+	RetainScale(existing, updates)
+	assert.EqualValues(t, 3, updates.Scale)
+
+	// Never deployed with a recorded scale
+	updates = &client.Service{Scale: 2}
+	RetainScale(&client.Service{Scale: 5}, updates)
+	assert.EqualValues(t, 2, updates.Scale)
+}
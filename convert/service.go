@@ -7,10 +7,11 @@ import (
 	"github.com/rancher/rancher-compose-executor/config"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
 )
 
-func Service(p *project.Project, name string) (*client.Service, error) {
-	launchConfig, secondaryLaunchConfigs, err := createLaunchConfigs(p, name)
+func Service(ctx context.Context, p *project.Project, name string) (*client.Service, error) {
+	launchConfig, secondaryLaunchConfigs, err := createLaunchConfigs(ctx, p, name)
 	if err != nil {
 		return nil, err
 	}
@@ -21,16 +22,22 @@ func Service(p *project.Project, name string) (*client.Service, error) {
 		LaunchConfig:           &launchConfig,
 		Name:                   name,
 		Metadata:               utils.NestedMapsToMapInterface(serviceConfig.Metadata),
-		Scale:                  max(1, int64(serviceConfig.Scale)),
 		StackId:                p.Stack.Id,
 		Selector:               serviceConfig.Labels["io.rancher.service.selector.container"],
 		ExternalIpAddresses:    serviceConfig.ExternalIps,
 		Hostname:               serviceConfig.Hostname,
-		HealthCheck:            serviceConfig.HealthCheck,
+		HealthCheck:            launchConfig.HealthCheck,
 		StorageDriver:          serviceConfig.StorageDriver,
 		NetworkDriver:          serviceConfig.NetworkDriver,
 		ServiceLinks:           populateServiceLink(serviceConfig),
 		SecondaryLaunchConfigs: secondaryLaunchConfigs,
+		BatchSize:              serviceConfig.UpgradeStrategy.BatchSize,
+		IntervalMillis:         serviceConfig.UpgradeStrategy.IntervalMillis,
+		StartFirst:             serviceConfig.UpgradeStrategy.StartFirst,
+	}
+
+	if err := populateScale(name, serviceConfig, &service); err != nil {
+		return nil, err
 	}
 
 	populateCreateOnly(&service)
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	catalog "github.com/rancher/go-rancher/catalog"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/metrics"
+)
+
+const (
+	cacheTTL  = 10 * time.Minute
+	cacheSize = 256
+)
+
+var (
+	projectClients   = newCache("clients", cacheTTL, cacheSize)
+	templateVersions = newCache("templateVersions", cacheTTL, cacheSize)
+)
+
+func init() {
+	metrics.OnScrape(func() {
+		for name, stats := range GetCacheStats() {
+			metrics.CacheHits.Set(float64(stats.Hits), name)
+			metrics.CacheMisses.Set(float64(stats.Misses), name)
+			metrics.CacheEntries.Set(float64(stats.Size), name)
+		}
+	})
+}
+
+// CacheStats are the hit and miss counts of a cache
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	Size   int
+}
+
+// GetCacheStats returns the stats of the caches used to construct projects,
+// keyed by cache name
+func GetCacheStats() map[string]CacheStats {
+	return map[string]CacheStats{
+		projectClients.name:   projectClients.stats(),
+		templateVersions.name: templateVersions.stats(),
+	}
+}
+
+// cache is a TTL cache holding at most size entries. When full, the entry
+// closest to expiring is evicted.
+type cache struct {
+	sync.Mutex
+	name    string
+	ttl     time.Duration
+	size    int
+	entries map[string]cacheEntry
+	hits    int64
+	misses  int64
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+func newCache(name string, ttl time.Duration, size int) *cache {
+	return &cache{
+		name:    name,
+		ttl:     ttl,
+		size:    size,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// get returns the cached value of key, calling load to populate it on a miss.
+// Errors and nil values are not cached.
+func (c *cache) get(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.Lock()
+	entry, ok := c.entries[key]
+	if ok && time.Now().Before(entry.expires) {
+		c.hits++
+		c.Unlock()
+		return entry.value, nil
+	}
+	c.misses++
+	c.Unlock()
+
+	value, err := load()
+	if err != nil || value == nil {
+		return value, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.evict()
+	c.entries[key] = cacheEntry{
+		value:   value,
+		expires: time.Now().Add(c.ttl),
+	}
+	return value, nil
+}
+
+func (c *cache) evict() {
+	now := time.Now()
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expires.Before(oldest) {
+			oldestKey = key
+			oldest = entry.expires
+		}
+	}
+	if len(c.entries) >= c.size && oldestKey != "" {
+		delete(c.entries, oldestKey)
+	}
+}
+
+func (c *cache) purge() {
+	c.Lock()
+	defer c.Unlock()
+	c.entries = map[string]cacheEntry{}
+}
+
+func (c *cache) stats() CacheStats {
+	c.Lock()
+	defer c.Unlock()
+	return CacheStats{
+		Hits:   c.hits,
+		Misses: c.misses,
+		Size:   len(c.entries),
+	}
+}
+
+// purgeCachesOnAuthError drops all cached clients and template versions if
+// err shows the credentials they were loaded with are no longer valid
+func purgeCachesOnAuthError(err error) {
+	if !isAuthError(err) {
+		return
+	}
+	logrus.Infof("Purging cached clients and template versions after auth error: %v", err)
+	projectClients.purge()
+	templateVersions.purge()
+}
+
+func isAuthError(err error) bool {
+	var statusCode int
+	switch apiError := errors.Cause(err).(type) {
+	case *client.ApiError:
+		statusCode = apiError.StatusCode
+	case *catalog.ApiError:
+		statusCode = apiError.StatusCode
+	default:
+		return false
+	}
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/go-rancher/catalog"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := newCache("test", time.Minute, 2)
+	loads := 0
+	load := func(value string) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			loads++
+			return value, nil
+		}
+	}
+
+	value, err := c.get("a", load("1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	value, _ = c.get("a", load("2"))
+	assert.Equal(t, "1", value)
+	assert.Equal(t, 1, loads)
+
+	c.get("b", load("b"))
+	c.get("c", load("c"))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Size: 2}, c.stats())
+
+	// a was evicted to make room for c
+	value, _ = c.get("a", load("3"))
+	assert.Equal(t, "3", value)
+
+	c.purge()
+	assert.Equal(t, 0, c.stats().Size)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := newCache("test", time.Millisecond, 2)
+	c.get("a", func() (interface{}, error) { return "1", nil })
+	time.Sleep(5 * time.Millisecond)
+
+	value, _ := c.get("a", func() (interface{}, error) { return "2", nil })
+	assert.Equal(t, "2", value)
+}
+
+func TestCacheErrorsNotCached(t *testing.T) {
+	c := newCache("test", time.Minute, 2)
+	_, err := c.get("a", func() (interface{}, error) { return nil, errors.New("failed") })
+	assert.Error(t, err)
+	assert.Equal(t, 0, c.stats().Size)
+}
+
+func TestIsAuthError(t *testing.T) {
+	assert.True(t, isAuthError(&client.ApiError{StatusCode: 401}))
+	assert.True(t, isAuthError(&client.ApiError{StatusCode: 403}))
+	assert.False(t, isAuthError(&client.ApiError{StatusCode: 404}))
+	assert.False(t, isAuthError(errors.New("failed")))
+}
+
+func TestTemplateVersionCachedPerProject(t *testing.T) {
+	defer templateVersions.purge()
+
+	// The catalog answers a template version of the project asking for it
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body interface{}
+		switch req.URL.Path {
+		case "/v1-catalog/schemas":
+			rw.Header().Set("X-API-Schemas", server.URL+"/v1-catalog/schemas")
+			body = catalog.Schemas{
+				Data: []catalog.Schema{{
+					Resource: catalog.Resource{
+						Id:   "templateVersion",
+						Type: "schema",
+						Links: map[string]string{
+							"collection": server.URL + "/v1-catalog/templateversions",
+						},
+					},
+					ResourceMethods: []string{"GET"},
+				}},
+			}
+		case "/v1-catalog/templateversions/project:wordpress:1":
+			body = catalog.TemplateVersion{
+				Resource: catalog.Resource{
+					Id:   "project:wordpress:1",
+					Type: "templateVersion",
+				},
+				Questions: []catalog.Question{{
+					Variable: "project",
+					Default:  req.Header.Get("X-API-Project-Id"),
+				}},
+			}
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(rw).Encode(body)
+	}))
+	defer server.Close()
+
+	apiClient := &client.RancherClient{
+		RancherBaseClient: &client.RancherBaseClientImpl{
+			Opts: &client.ClientOpts{Url: server.URL + "/v3"},
+		},
+	}
+	misses := templateVersions.stats().Misses
+	for _, accountId := range []string{"1a5", "1a6", "1a5"} {
+		templateVersion, err := getTemplateVersion(&client.Stack{
+			AccountId:  accountId,
+			ExternalId: "catalog://project:wordpress:1",
+		}, apiClient)
+		if assert.NoError(t, err) && assert.Len(t, templateVersion.Questions, 1) {
+			assert.Equal(t, accountId, templateVersion.Questions[0].Default)
+		}
+	}
+	assert.Equal(t, misses+2, templateVersions.stats().Misses)
+}
@@ -5,24 +5,41 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/rancher/event-subscriber/events"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/project/progress"
+	"github.com/rancher/rancher-compose-executor/redact"
 	"github.com/rancher/rancher-compose-executor/resources/service"
 )
 
-func keepalive(request *events.Event, apiClient *client.RancherClient) (stopFunc func()) {
+const (
+	progressInterval  = time.Second
+	keepaliveInterval = 5 * time.Second
+)
+
+// keepalive publishes the progress of the request whenever it changes, and
+// at least every few seconds so the request isn't considered stuck
+func keepalive(request *events.Event, apiClient *client.RancherClient, p *progress.Progress) (stopFunc func()) {
 	ctx, cancel := context.WithCancel(context.Background())
 	innerCtx, innerCancel := context.WithCancel(context.Background())
 	go func() {
 		defer innerCancel()
+		_, lastVersion := p.Message()
+		lastPublished := time.Now()
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-time.After(5 * time.Second):
+			case <-time.After(progressInterval):
+			}
+			message, version := p.Message()
+			if version == lastVersion && time.Since(lastPublished) < keepaliveInterval {
+				continue
 			}
-			publishTransitioningReply("", request, apiClient, false)
+			publishTransitioningReply(message, request, apiClient, false)
+			lastVersion = version
+			lastPublished = time.Now()
 		}
 	}()
 	return func() {
@@ -50,10 +67,20 @@ func publishTransitioningReply(msg string, request *events.Event, apiClient *cli
 		replyT.Transitioning = "yes"
 	}
 
-	replyT.TransitioningMessage = msg
+	replyT.TransitioningMessage = redact.String(msg)
 	publishReply(replyT, apiClient)
 }
 
+// publishSummary reports what was changed once the request is done
+func publishSummary(ctx context.Context, request *events.Event, apiClient *client.RancherClient, p *progress.Progress) {
+	summary := p.Summary()
+	if summary == "" {
+		return
+	}
+	logging.FromContext(ctx).Info(summary)
+	publishTransitioningReply(summary, request, apiClient, false)
+}
+
 func newReply(event *events.Event) *client.Publish {
 	return &client.Publish{
 		Name:        event.ReplyTo,
@@ -65,7 +92,7 @@ func WithTimeout(f func(event *events.Event, apiClient *client.RancherClient) er
 	return func(event *events.Event, apiClient *client.RancherClient) error {
 		err := f(event, apiClient)
 		if err == service.ErrTimeout {
-			logrus.Infof("Timeout processing %s", fmt.Sprintf("%s:%s", event.ResourceType, event.ResourceID))
+			eventLogger(event).Infof("Timeout processing %s", fmt.Sprintf("%s:%s", event.ResourceType, event.ResourceID))
 			return nil
 		}
 		return err
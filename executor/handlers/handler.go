@@ -1,92 +1,208 @@
 package handlers
 
 import (
+	"context"
 	"errors"
-
-	"golang.org/x/net/context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/rancher/event-subscriber/events"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/metrics"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 	"github.com/rancher/rancher-compose-executor/resources/service"
 )
 
-type stackAction func(event *events.Event, apiClient *client.RancherClient) error
+type stackAction func(ctx context.Context, event *events.Event, apiClient *client.RancherClient) error
 
 func CreateStack(event *events.Event, apiClient *client.RancherClient) error {
-	return doAction(event, apiClient, "Create Stack", func(event *events.Event, apiClient *client.RancherClient) error {
-		return stackUp(event, apiClient, true)
+	opts, err := getOptions(event)
+	return doAction(event, apiClient, "Create Stack", deployKind(opts, err), func(ctx context.Context, event *events.Event, apiClient *client.RancherClient) error {
+		if err != nil {
+			return err
+		}
+		return stackUp(ctx, event, apiClient, true, opts)
 	})
 }
 
 func UpdateStack(event *events.Event, apiClient *client.RancherClient) error {
-	return doAction(event, apiClient, "Update Stack", func(event *events.Event, apiClient *client.RancherClient) error {
-		return stackUp(event, apiClient, true)
+	opts, err := getOptions(event)
+	opts.Prune = true
+	return doAction(event, apiClient, "Update Stack", deployKind(opts, err), func(ctx context.Context, event *events.Event, apiClient *client.RancherClient) error {
+		if err != nil {
+			return err
+		}
+		return stackUp(ctx, event, apiClient, true, opts)
 	})
 }
 
 func DeleteStack(event *events.Event, apiClient *client.RancherClient) error {
-	return doAction(event, apiClient, "Delete Stack", stackDelete)
+	return doAction(event, apiClient, "Delete Stack", removeRun, stackDelete)
+}
+
+func RollbackStack(event *events.Event, apiClient *client.RancherClient) error {
+	return stackProjectAction(event, apiClient, "Rollback Stack", "Rolling back stack", project.RollbackAction)
+}
+
+func FinishUpgradeStack(event *events.Event, apiClient *client.RancherClient) error {
+	return stackProjectAction(event, apiClient, "Finish Upgrade Stack", "Finishing upgrade of stack", project.FinishUpgradeAction)
+}
+
+func ActivateStack(event *events.Event, apiClient *client.RancherClient) error {
+	return stackProjectAction(event, apiClient, "Activate Stack", "Activating stack", project.ActivateAction)
+}
+
+func DeactivateStack(event *events.Event, apiClient *client.RancherClient) error {
+	return stackProjectAction(event, apiClient, "Deactivate Stack", "Deactivating stack", project.DeactivateAction)
 }
 
-func doAction(event *events.Event, apiClient *client.RancherClient, msg string, action stackAction) error {
-	logger := logrus.WithFields(logrus.Fields{
-		"resourceId": event.ResourceID,
-		"eventId":    event.ID,
+// stackProjectAction applies a stack wide action to the services selected
+// by the event, or all of them
+func stackProjectAction(event *events.Event, apiClient *client.RancherClient, msg, progressMsg string, action project.Action) error {
+	opts, err := getOptions(event)
+	return doAction(event, apiClient, msg, actionRun, func(ctx context.Context, event *events.Event, apiClient *client.RancherClient) error {
+		if err != nil {
+			return err
+		}
+		return withStackProject(ctx, event, apiClient, progressMsg, func(ctx context.Context, p *project.Project) error {
+			return p.Action(ctx, action, opts)
+		})
 	})
+}
+
+// doAction runs the action once the earlier events of the same stack are
+// done, queued according to kind. A pending retry of an earlier event of the
+// stack is dropped, as this event is newer.
+func doAction(event *events.Event, apiClient *client.RancherClient, msg string, kind runKind, action stackAction) error {
+	if retries.cancel(event.ResourceID) {
+		eventLogger(event).Infof("Cancelled retry of an earlier event of the stack")
+	}
+	return runAction(event, apiClient, msg, kind, action, 1, time.Now())
+}
 
-	logger.Infof("%s Event Received", msg)
+// runAction runs an attempt of the action. While the cluster isn't ready the
+// action is retried with backoff until ClusterReadyTimeout has passed since
+// the first attempt.
+func runAction(event *events.Event, apiClient *client.RancherClient, msg string, kind runKind, action stackAction, attempt int, firstAttempt time.Time) error {
+	logger := eventLogger(event)
+
+	eventType := EventType(event)
+	start := time.Now()
+	defer func() {
+		metrics.EventDuration.Observe(time.Since(start).Seconds(), eventType)
+	}()
+
+	if attempt == 1 {
+		metrics.EventsReceived.Inc(eventType)
+		logger.Infof("%s Event Received", msg)
+	} else {
+		logger.Infof("%s Event Retried, attempt %d", msg, attempt)
+	}
 
-	if err := action(event, apiClient); err != nil {
-		if project.IsErrClusterNotReady(err) {
-			publishTransitioningReply("Waiting for cluster to be ready", event, apiClient, false)
+	err := stacks.run(event.ResourceID, kind, func(ctx context.Context) error {
+		return action(logging.WithLogger(ctx, logger), event, apiClient)
+	})
+	if err == errStackRemoved {
+		metrics.EventsSucceeded.Inc(eventType)
+		logger.Infof("%s Event Cancelled: %v", msg, err)
+		return emptyReply(event, apiClient)
+	}
+	if err == errShuttingDown {
+		// Left without a reply, so that the event is delivered again
+		logger.Infof("%s Event Interrupted: %v", msg, err)
+		publishTransitioningReply("Interrupted as rancher-compose-executor is shutting down, waiting for the event to be delivered again", event, apiClient, false)
+		return nil
+	}
+	if project.IsErrClusterNotReady(err) {
+		metrics.EventsClusterNotReady.Inc(eventType)
+		if time.Since(firstAttempt) < ClusterReadyTimeout {
+			delay := retries.schedule(event.ResourceID, attempt, func() {
+				runAction(event, apiClient, msg, kind, action, attempt+1, firstAttempt)
+			})
+			logger.Infof("%s Event Waiting for cluster, retrying in %v: %v", msg, delay, err)
+			publishTransitioningReply(fmt.Sprintf("Waiting for cluster to be ready (attempt %d, retrying in %v)", attempt, delay), event, apiClient, false)
 			return nil
 		}
+		err = fmt.Errorf("Cluster is not ready after %d attempts: %v", attempt, err)
+	}
+	if err != nil {
+		purgeCachesOnAuthError(err)
 		logger.Errorf("%s Event Failed: %v", msg, err)
-		if err != service.ErrTimeout {
+		if err == service.ErrTimeout {
+			metrics.EventsTimedOut.Inc(eventType)
+		} else {
+			metrics.EventsFailed.Inc(eventType)
 			publishTransitioningReply(err.Error(), event, apiClient, true)
 		}
 		return err
 	}
 
+	metrics.EventsSucceeded.Inc(eventType)
 	logger.Infof("%s Event Done", msg)
 	return emptyReply(event, apiClient)
 }
 
-func stackUp(event *events.Event, apiClient *client.RancherClient, forceUp bool) error {
-	project, err := createStackProject(event, apiClient)
-	if err != nil || project == nil {
-		return err
-	}
+// eventLogger logs with the stack and event the lines logged while handling
+// the event
+func eventLogger(event *events.Event) *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{
+		logging.StackID: event.ResourceID,
+		logging.EventID: event.ID,
+		logging.Event:   EventType(event),
+	})
+}
 
-	publishTransitioningReply("Creating stack", event, apiClient, false)
-	defer keepalive(event, apiClient)()
+// EventType is the name of the event without the handler it is sent to,
+// such as stack.create
+func EventType(event *events.Event) string {
+	return strings.SplitN(event.Name, ";", 2)[0]
+}
 
-	if err := project.Create(context.Background(), options.Options{}); err != nil {
-		return err
-	}
-	if forceUp {
-		return project.Up(context.Background(), options.Options{})
-	}
+func stackUp(ctx context.Context, event *events.Event, apiClient *client.RancherClient, forceUp bool, opts options.Options) error {
+	return withStackProject(ctx, event, apiClient, "Creating stack", func(ctx context.Context, p *project.Project) error {
+		if err := p.Create(ctx, opts); err != nil || !forceUp {
+			return err
+		}
+		return p.Up(ctx, opts)
+	})
+}
 
-	return nil
+func stackDelete(ctx context.Context, event *events.Event, apiClient *client.RancherClient) error {
+	return withStackProject(ctx, event, apiClient, "Deleting stack", func(ctx context.Context, p *project.Project) error {
+		return p.Delete(ctx)
+	})
 }
 
-func stackDelete(event *events.Event, apiClient *client.RancherClient) error {
-	project, err := createStackProject(event, apiClient)
+// withStackProject loads the project of the stack and runs f on it,
+// publishing its progress while it runs and a summary once it is done
+func withStackProject(ctx context.Context, event *events.Event, apiClient *client.RancherClient, msg string, f func(ctx context.Context, p *project.Project) error) error {
+	project, err := createStackProject(ctx, event, apiClient)
 	if err != nil || project == nil {
 		return err
 	}
+	ctx = logging.WithField(ctx, logging.StackName, project.Name)
+
+	project.Progress = progress.New(msg)
+	publishTransitioningReply(msg, event, apiClient, false)
+	stop := keepalive(event, apiClient, project.Progress)
 
-	publishTransitioningReply("Deleting stack", event, apiClient, false)
-	defer keepalive(event, apiClient)()
+	err = f(ctx, project)
+	stop()
 
-	return project.Delete(context.Background())
+	if err != nil {
+		return err
+	}
+
+	publishSummary(ctx, event, apiClient, project.Progress)
+	return nil
 }
 
-func createStackProject(event *events.Event, apiClient *client.RancherClient) (*project.Project, error) {
+func createStackProject(ctx context.Context, event *events.Event, apiClient *client.RancherClient) (*project.Project, error) {
 	stack, err := apiClient.Stack.ById(event.ResourceID)
 	if err != nil {
 		return nil, err
@@ -103,6 +219,7 @@ func createStackProject(event *events.Event, apiClient *client.RancherClient) (*
 		return nil, errors.New("Failed to find cluster")
 	}
 
-	project, err := constructProject(stack, cluster, *apiClient.GetOpts())
+	ctx = logging.WithField(ctx, logging.StackName, stack.Name)
+	project, err := constructProject(ctx, stack, cluster, *apiClient.GetOpts())
 	return project, err
 }
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/event-subscriber/events"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/fakecattle"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/metrics"
+	"github.com/rancher/rancher-compose-executor/redact"
+	"github.com/rancher/rancher-compose-executor/resources/service"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCompose = `
+version: "2"
+services:
+  web:
+    image: nginx:${VERSION}
+`
+
+func newTestStack(t *testing.T, s *fakecattle.Server, templates map[string]string) (*client.RancherClient, *client.Stack) {
+	apiClient, err := s.Client()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	accountId := s.Add("account", client.Account{ExternalId: "test"})
+	clusterId := s.Add("cluster", client.Cluster{Name: "default"})
+	stackId := s.Add("stack", client.Stack{
+		Name:      "test",
+		AccountId: accountId,
+		ClusterId: clusterId,
+		Templates: templates,
+		Answers: map[string]interface{}{
+			"VERSION": "1.12",
+		},
+	})
+
+	stack, err := apiClient.Stack.ById(stackId)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return apiClient, stack
+}
+
+func newTestEvent(name, stackId string) *events.Event {
+	return &events.Event{
+		ID:           name + "-" + stackId,
+		Name:         name,
+		ReplyTo:      "reply." + name,
+		ResourceID:   stackId,
+		ResourceType: "stack",
+	}
+}
+
+// assertReplied checks that the event was replied to and returns the
+// transitioning messages published for it
+func assertReplied(t *testing.T, s *fakecattle.Server, event *events.Event, transitioning string) []string {
+	var messages []string
+	var last *client.Publish
+	for _, publish := range s.Publishes() {
+		if len(publish.PreviousIds) == 1 && publish.PreviousIds[0] == event.ID {
+			assert.Equal(t, event.ReplyTo, publish.Name)
+			messages = append(messages, publish.TransitioningMessage)
+			last = &publish
+		}
+	}
+	if assert.NotNil(t, last, "no reply to %s", event.Name) {
+		assert.Equal(t, transitioning, last.Transitioning)
+	}
+	return messages
+}
+
+func TestCreateUpdateRollbackStack(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	apiClient, stack := newTestStack(t, s, map[string]string{
+		"compose.yml": testCompose,
+	})
+
+	event := newTestEvent("stack.create", stack.Id)
+	assert.NoError(t, CreateStack(event, apiClient))
+	messages := assertReplied(t, s, event, "")
+	assert.Contains(t, messages, "Creating stack")
+	assert.Contains(t, messages, "Created service web")
+
+	var web client.Service
+	if assert.True(t, s.Find("service", "web", &web)) {
+		assert.Equal(t, "active", web.State)
+		assert.Equal(t, "nginx:1.12", web.LaunchConfig.Image)
+	}
+
+	_, err := apiClient.Stack.Update(stack, map[string]interface{}{
+		"answers": map[string]interface{}{
+			"VERSION": "1.13",
+		},
+	})
+	assert.NoError(t, err)
+
+	event = newTestEvent("stack.update", stack.Id)
+	assert.NoError(t, UpdateStack(event, apiClient))
+	assert.Contains(t, assertReplied(t, s, event, ""), "Upgraded service web")
+	if assert.True(t, s.Find("service", "web", &web)) {
+		assert.Equal(t, "upgraded", web.State)
+		assert.Equal(t, "nginx:1.13", web.LaunchConfig.Image)
+	}
+
+	event = newTestEvent("stack.rollback", stack.Id)
+	assert.NoError(t, RollbackStack(event, apiClient))
+	assert.Contains(t, assertReplied(t, s, event, ""), "Rolled back service web")
+	if assert.True(t, s.Find("service", "web", &web)) {
+		assert.Equal(t, "active", web.State)
+		assert.Equal(t, "nginx:1.12", web.LaunchConfig.Image)
+	}
+
+	event = newTestEvent("stack.remove", stack.Id)
+	assert.NoError(t, DeleteStack(event, apiClient))
+	assertReplied(t, s, event, "")
+}
+
+func TestCreateStackInvalidTemplate(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	apiClient, stack := newTestStack(t, s, map[string]string{
+		"compose.yml": "services: [",
+	})
+
+	event := newTestEvent("stack.create", stack.Id)
+	assert.Error(t, CreateStack(event, apiClient))
+	messages := assertReplied(t, s, event, "error")
+	if assert.NotEmpty(t, messages) {
+		assert.Contains(t, messages[len(messages)-1], "Could not parse config")
+	}
+	assert.Equal(t, 0, s.Count("service"))
+}
+
+func TestTransitioningMessagesRedacted(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	apiClient, err := s.Client()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	redact.Register("reply-s3cr3t")
+	event := newTestEvent("stack.create", "1st1")
+	publishTransitioningReply("Failed: reply-s3cr3t, token=abcdef", event, apiClient, true)
+	assert.Equal(t, []string{"Failed: [REDACTED], token=[REDACTED]"}, assertReplied(t, s, event, "error"))
+}
+
+func TestEventMetrics(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	apiClient, stack := newTestStack(t, s, map[string]string{
+		"compose.yml": testCompose,
+	})
+	_, invalid := newTestStack(t, s, map[string]string{
+		"compose.yml": "services: [",
+	})
+
+	received := metrics.EventsReceived.Value("stack.create")
+	succeeded := metrics.EventsSucceeded.Value("stack.create")
+	failed := metrics.EventsFailed.Value("stack.create")
+
+	event := newTestEvent("stack.create", stack.Id)
+	event.Name = "stack.create;handler=rancher-compose-executor"
+	assert.NoError(t, CreateStack(event, apiClient))
+
+	event = newTestEvent("stack.create", invalid.Id)
+	assert.Error(t, CreateStack(event, apiClient))
+
+	assert.Equal(t, received+2, metrics.EventsReceived.Value("stack.create"))
+	assert.Equal(t, succeeded+1, metrics.EventsSucceeded.Value("stack.create"))
+	assert.Equal(t, failed+1, metrics.EventsFailed.Value("stack.create"))
+	assert.Equal(t, float64(0), metrics.InFlightStacks.Value())
+	assert.NotZero(t, metrics.WaitPolls.Value("service"))
+}
+
+func TestShutdownInterruptsEvents(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	defer func(q *stackQueue, r *retryScheduler) {
+		stacks, retries = q, r
+	}(stacks, retries)
+	stacks, retries = newStackQueue(), newRetryScheduler(time.Hour, time.Hour)
+
+	apiClient, stack := newTestStack(t, s, map[string]string{
+		"compose.yml": testCompose,
+	})
+
+	started := make(chan struct{})
+	result := make(chan error)
+	event := newTestEvent("stack.update", stack.Id)
+	go func() {
+		result <- doAction(event, apiClient, "Update Stack", deployRun, func(ctx context.Context, event *events.Event, apiClient *client.RancherClient) error {
+			close(started)
+			<-ctx.Done()
+			return service.ErrTimeout
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	Shutdown(ctx)
+
+	assert.NoError(t, <-result)
+	messages := assertReplied(t, s, event, "yes")
+	if assert.NotEmpty(t, messages) {
+		assert.Contains(t, messages[len(messages)-1], "Interrupted as rancher-compose-executor is shutting down")
+	}
+
+	event = newTestEvent("stack.create", stack.Id)
+	assert.NoError(t, CreateStack(event, apiClient))
+	assertReplied(t, s, event, "yes")
+	assert.Equal(t, 0, s.Count("service"))
+}
+
+func TestEventLogFields(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	defer logrus.SetOutput(logrus.StandardLogger().Out)
+	defer logrus.SetFormatter(logrus.StandardLogger().Formatter)
+	defer logrus.SetLevel(logrus.GetLevel())
+	buf := &bytes.Buffer{}
+	logrus.SetOutput(buf)
+	logrus.SetLevel(logrus.DebugLevel)
+	assert.NoError(t, logging.SetFormat("json"))
+
+	apiClient, stack := newTestStack(t, s, map[string]string{
+		"compose.yml": testCompose,
+	})
+
+	event := newTestEvent("stack.create", stack.Id)
+	assert.NoError(t, CreateStack(event, apiClient))
+
+	found := false
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		line := map[string]interface{}{}
+		if !assert.NoError(t, decoder.Decode(&line)) {
+			break
+		}
+		assert.Equal(t, stack.Id, line[logging.StackID], line["msg"])
+		assert.Equal(t, event.ID, line[logging.EventID], line["msg"])
+		if line["msg"] == "Creating service web" {
+			found = true
+			assert.Equal(t, stack.Name, line[logging.StackName])
+			assert.Equal(t, "web", line[logging.Service])
+			assert.Equal(t, "create", line[logging.Phase])
+		}
+	}
+	assert.True(t, found, "no log line for creating service web")
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/rancher/event-subscriber/events"
+	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/utils"
+)
+
+// eventOptions are the deploy options an event can carry in its data, for
+// example {"options": {"services": ["web"], "forceRecreate": true}}
+type eventOptions struct {
+	Services      []string `json:"services,omitempty"`
+	ForceRecreate bool     `json:"forceRecreate,omitempty"`
+	NoRecreate    bool     `json:"noRecreate,omitempty"`
+}
+
+func getOptions(event *events.Event) (options.Options, error) {
+	data, ok := event.Data["options"]
+	if !ok || data == nil {
+		return options.Options{}, nil
+	}
+
+	var parsed eventOptions
+	if err := utils.ConvertByJSON(data, &parsed); err != nil {
+		return options.Options{}, fmt.Errorf("Invalid options in event %s: %v", event.ID, err)
+	}
+
+	return options.Options{
+		Services:      parsed.Services,
+		ForceRecreate: parsed.ForceRecreate,
+		NoRecreate:    parsed.NoRecreate,
+	}, nil
+}
+
+// deployKind queues a deploy of only some services, or one that recreates
+// them, separately from plain deploys so it is never coalesced away. The same
+// goes for events with invalid options, so the error is reported on them.
+func deployKind(opts options.Options, err error) runKind {
+	if err != nil || len(opts.Services) > 0 || opts.ForceRecreate || opts.NoRecreate {
+		return actionRun
+	}
+	return deployRun
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/rancher/event-subscriber/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOptions(t *testing.T) {
+	opts, err := getOptions(&events.Event{
+		Data: map[string]interface{}{
+			"options": map[string]interface{}{
+				"services":      []interface{}{"web"},
+				"forceRecreate": true,
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web"}, opts.Services)
+	assert.True(t, opts.ForceRecreate)
+	assert.False(t, opts.NoRecreate)
+	assert.Equal(t, actionRun, deployKind(opts, err))
+
+	opts, err = getOptions(&events.Event{})
+	assert.NoError(t, err)
+	assert.Empty(t, opts.Services)
+	assert.Equal(t, deployRun, deployKind(opts, err))
+
+	opts, err = getOptions(&events.Event{
+		Data: map[string]interface{}{
+			"options": map[string]interface{}{
+				"services": "web",
+			},
+		},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, actionRun, deployKind(opts, err))
+}
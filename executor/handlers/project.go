@@ -11,19 +11,23 @@ import (
 	"github.com/rancher/rancher-compose-executor/project"
 	_ "github.com/rancher/rancher-compose-executor/resources"
 	"github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
 )
 
-func constructProject(stack *client.Stack, cluster *client.Cluster, opts client.ClientOpts) (*project.Project, error) {
+func constructProject(ctx context.Context, stack *client.Stack, cluster *client.Cluster, opts client.ClientOpts) (*project.Project, error) {
 	if stack.ExternalId == "" && len(stack.Templates) == 0 {
 		return nil, nil
 	}
 
-	// TODO: don't create each time
-	opts.Url = fmt.Sprintf("%s/projects/%s/schemas", opts.Url, stack.AccountId)
-	rancherClient, err := client.NewRancherClient(&opts)
+	rancherClient, err := getProjectClient(stack, opts)
+	if err != nil {
+		purgeCachesOnAuthError(err)
+		return nil, err
+	}
 
-	templateVersion, err := loadTemplateVersion(stack, rancherClient)
+	templateVersion, err := getTemplateVersion(stack, rancherClient)
 	if err != nil {
+		purgeCachesOnAuthError(err)
 		return nil, err
 	}
 
@@ -31,10 +35,40 @@ func constructProject(stack *client.Stack, cluster *client.Cluster, opts client.
 
 	p := project.NewProject(stack.Name, rancherClient, cluster)
 	if templateVersion == nil {
-		return p, p.Load(stack.Templates, answers)
+		return p, p.Load(ctx, stack.Templates, answers)
+	}
+
+	return p, p.LoadFromTemplateVersion(ctx, *templateVersion, answers)
+}
+
+func getProjectClient(stack *client.Stack, opts client.ClientOpts) (*client.RancherClient, error) {
+	opts.Url = fmt.Sprintf("%s/projects/%s/schemas", opts.Url, stack.AccountId)
+	value, err := projectClients.get(opts.Url+"|"+opts.AccessKey, func() (interface{}, error) {
+		return client.NewRancherClient(&opts)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return value.(*client.RancherClient), nil
+}
 
-	return p, p.LoadFromTemplateVersion(*templateVersion, answers)
+func getTemplateVersion(stack *client.Stack, rancherClient *client.RancherClient) (*catalog.TemplateVersion, error) {
+	if !strings.HasPrefix(stack.ExternalId, "catalog://") {
+		return nil, nil
+	}
+	// Catalogs can be scoped to a project, so the template version is
+	// loaded as the project of the stack
+	value, err := templateVersions.get(stack.AccountId+"|"+stack.ExternalId, func() (interface{}, error) {
+		templateVersion, err := loadTemplateVersion(stack, rancherClient)
+		if templateVersion == nil {
+			return nil, err
+		}
+		return templateVersion, err
+	})
+	if value == nil || err != nil {
+		return nil, err
+	}
+	return value.(*catalog.TemplateVersion), nil
 }
 
 func buildAnswers(stack *client.Stack, templateVersion *catalog.TemplateVersion) map[string]string {
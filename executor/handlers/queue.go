@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rancher/rancher-compose-executor/metrics"
+)
+
+var (
+	errStackRemoved = errors.New("Stack is being removed")
+	errShuttingDown = errors.New("rancher-compose-executor is shutting down")
+)
+
+var stacks = newStackQueue()
+
+// runKind decides how an event of a stack is queued behind the others
+type runKind int
+
+const (
+	// deployRun deploys the latest state of the stack
+	deployRun runKind = iota
+	// actionRun applies an action such as a rollback, which must run once
+	// for every event received
+	actionRun
+	// removeRun makes every earlier event of the stack obsolete
+	removeRun
+)
+
+// stackQueue runs the events of a stack one at a time. A deploy that
+// arrives while another one is waiting is coalesced into it, as both deploy
+// the latest state of the stack. A remove cancels everything that is still
+// running before it runs. Once closed, no new event runs.
+type stackQueue struct {
+	sync.Mutex
+	runs   map[string][]*stackRun
+	closed bool
+}
+
+type stackRun struct {
+	kind    runKind
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	// cause is why the run was cancelled
+	cause error
+	done  chan struct{}
+	err   error
+}
+
+func newStackQueue() *stackQueue {
+	return &stackQueue{
+		runs: map[string][]*stackRun{},
+	}
+}
+
+// run calls f once every earlier event of the stack is done. It returns
+// errStackRemoved if the stack was removed before f completed, and
+// errShuttingDown if the queue was closed before f completed.
+func (q *stackQueue) run(stackID string, kind runKind, f func(ctx context.Context) error) error {
+	q.Lock()
+	if q.closed {
+		q.Unlock()
+		return errShuttingDown
+	}
+	runs := q.runs[stackID]
+
+	var previous *stackRun
+	if len(runs) > 0 {
+		previous = runs[len(runs)-1]
+	}
+
+	if kind == deployRun && previous != nil && previous.kind == deployRun && !previous.started {
+		q.Unlock()
+		<-previous.done
+		return previous.err
+	}
+
+	if kind == removeRun {
+		for _, r := range runs {
+			if r.kind != removeRun {
+				r.stop(errStackRemoved)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &stackRun{
+		kind:   kind,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if len(runs) == 0 {
+		metrics.InFlightStacks.Inc()
+	}
+	q.runs[stackID] = append(runs, r)
+	q.Unlock()
+
+	if previous != nil {
+		<-previous.done
+	}
+
+	q.Lock()
+	r.started = true
+	q.Unlock()
+
+	if ctx.Err() == nil {
+		r.err = f(ctx)
+	}
+	if ctx.Err() != nil {
+		q.Lock()
+		r.err = r.cause
+		q.Unlock()
+	}
+	cancel()
+
+	q.finish(stackID, r)
+	return r.err
+}
+
+func (q *stackQueue) finish(stackID string, r *stackRun) {
+	q.Lock()
+	defer q.Unlock()
+
+	runs := q.runs[stackID]
+	for i, existing := range runs {
+		if existing == r {
+			runs = append(runs[:i], runs[i+1:]...)
+			break
+		}
+	}
+	if len(runs) == 0 {
+		delete(q.runs, stackID)
+		metrics.InFlightStacks.Dec()
+	} else {
+		q.runs[stackID] = runs
+	}
+
+	close(r.done)
+}
+
+// stop cancels the run for the cause, unless it was already cancelled. The
+// caller holds the lock of the queue.
+func (r *stackRun) stop(cause error) {
+	if r.cause == nil {
+		r.cause = cause
+		r.cancel()
+	}
+}
+
+// close stops new events from running
+func (q *stackQueue) close() {
+	q.Lock()
+	defer q.Unlock()
+	q.closed = true
+}
+
+// interrupt cancels every event running or queued and returns the number of
+// stacks they belong to
+func (q *stackQueue) interrupt() int {
+	q.Lock()
+	defer q.Unlock()
+	for _, runs := range q.runs {
+		for _, r := range runs {
+			r.stop(errShuttingDown)
+		}
+	}
+	return len(q.runs)
+}
+
+// wait returns true once no event runs, or false if ctx is done first
+func (q *stackQueue) wait(ctx context.Context) bool {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		q.Lock()
+		idle := len(q.runs) == 0
+		q.Unlock()
+		if idle {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStackQueueCoalescesUpdates(t *testing.T) {
+	q := newStackQueue()
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var lock sync.Mutex
+	calls := 0
+	update := func(ctx context.Context) error {
+		lock.Lock()
+		calls++
+		lock.Unlock()
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, q.run("1s1", deployRun, update))
+	}()
+	<-started
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, q.run("1s1", deployRun, update))
+		}()
+	}
+	waitForRuns(q, "1s1", 2)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, q.runs)
+}
+
+func TestStackQueueRemoveCancels(t *testing.T) {
+	q := newStackQueue()
+	started := make(chan struct{})
+
+	result := make(chan error)
+	go func() {
+		result <- q.run("1s1", deployRun, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+	<-started
+
+	removed := false
+	assert.NoError(t, q.run("1s1", removeRun, func(ctx context.Context) error {
+		removed = true
+		return nil
+	}))
+
+	assert.Equal(t, errStackRemoved, <-result)
+	assert.True(t, removed)
+}
+
+func TestStackQueueRunsEveryAction(t *testing.T) {
+	q := newStackQueue()
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var lock sync.Mutex
+	calls := 0
+	action := func(ctx context.Context) error {
+		lock.Lock()
+		calls++
+		lock.Unlock()
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, q.run("1s1", deployRun, action))
+	}()
+	<-started
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, q.run("1s1", actionRun, action))
+		}()
+	}
+	waitForRuns(q, "1s1", 3)
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 3, calls)
+	assert.Empty(t, q.runs)
+}
+
+func TestStackQueueInterrupt(t *testing.T) {
+	q := newStackQueue()
+	started := make(chan struct{})
+
+	result := make(chan error)
+	go func() {
+		result <- q.run("1s1", deployRun, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+	<-started
+
+	q.close()
+	assert.Equal(t, errShuttingDown, q.run("1s2", deployRun, func(ctx context.Context) error {
+		assert.Fail(t, "ran after close")
+		return nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.False(t, q.wait(ctx))
+
+	assert.Equal(t, 1, q.interrupt())
+	assert.Equal(t, errShuttingDown, <-result)
+	assert.True(t, q.wait(context.Background()))
+}
+
+func waitForRuns(q *stackQueue, stackID string, count int) {
+	for i := 0; i < 100; i++ {
+		q.Lock()
+		n := len(q.runs[stackID])
+		q.Unlock()
+		if n == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
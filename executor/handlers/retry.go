@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// ClusterReadyTimeout is how long the events of a stack are retried while
+// the Kubernetes API of its cluster doesn't answer
+var ClusterReadyTimeout = time.Hour
+
+var retries = newRetryScheduler(5*time.Second, 5*time.Minute)
+
+// retryScheduler retries the event of a stack with exponential backoff. A
+// stack has at most one pending retry, which is cancelled when a newer event
+// of the stack arrives.
+type retryScheduler struct {
+	sync.Mutex
+	initial time.Duration
+	max     time.Duration
+	pending map[string]*retry
+	stopped bool
+}
+
+type retry struct {
+	timer *time.Timer
+	f     func()
+}
+
+func newRetryScheduler(initial, max time.Duration) *retryScheduler {
+	return &retryScheduler{
+		initial: initial,
+		max:     max,
+		pending: map[string]*retry{},
+	}
+}
+
+// backoff returns the delay before the retry following attempt
+func (s *retryScheduler) backoff(attempt int) time.Duration {
+	delay := s.initial
+	for i := 1; i < attempt && delay < s.max; i++ {
+		delay *= 2
+	}
+	if delay > s.max {
+		delay = s.max
+	}
+	return delay
+}
+
+// schedule calls f once the backoff of attempt has passed, unless the retry
+// is cancelled first, and returns the delay. Once the scheduler is stopped f
+// is called right away.
+func (s *retryScheduler) schedule(stackID string, attempt int, f func()) time.Duration {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stopped {
+		go f()
+		return 0
+	}
+
+	if existing, ok := s.pending[stackID]; ok {
+		existing.timer.Stop()
+	}
+
+	delay := s.backoff(attempt)
+	r := &retry{f: f}
+	r.timer = time.AfterFunc(delay, func() {
+		s.Lock()
+		current := s.pending[stackID] == r
+		if current {
+			delete(s.pending, stackID)
+		}
+		s.Unlock()
+
+		if current {
+			f()
+		}
+	})
+	s.pending[stackID] = r
+
+	return delay
+}
+
+// stop calls the pending retries right away, rather than after their
+// backoff, and returns once they are done
+func (s *retryScheduler) stop() {
+	s.Lock()
+	s.stopped = true
+	pending := s.pending
+	s.pending = map[string]*retry{}
+	s.Unlock()
+
+	var wg sync.WaitGroup
+	for _, r := range pending {
+		if !r.timer.Stop() {
+			continue
+		}
+		wg.Add(1)
+		go func(f func()) {
+			defer wg.Done()
+			f()
+		}(r.f)
+	}
+	wg.Wait()
+}
+
+// cancel drops the pending retry of the stack and returns whether there
+// was one
+func (s *retryScheduler) cancel(stackID string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	r, ok := s.pending[stackID]
+	if !ok {
+		return false
+	}
+	r.timer.Stop()
+	delete(s.pending, stackID)
+	return true
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	s := newRetryScheduler(5*time.Second, time.Minute)
+
+	assert.Equal(t, 5*time.Second, s.backoff(1))
+	assert.Equal(t, 10*time.Second, s.backoff(2))
+	assert.Equal(t, 40*time.Second, s.backoff(4))
+	assert.Equal(t, time.Minute, s.backoff(5))
+	assert.Equal(t, time.Minute, s.backoff(100))
+}
+
+func TestRetryCancel(t *testing.T) {
+	s := newRetryScheduler(10*time.Millisecond, time.Second)
+
+	retried := make(chan string, 2)
+	s.schedule("1s1", 1, func() {
+		retried <- "1s1"
+	})
+	s.schedule("1s2", 1, func() {
+		retried <- "1s2"
+	})
+
+	assert.True(t, s.cancel("1s1"))
+	assert.False(t, s.cancel("1s1"))
+
+	select {
+	case stackID := <-retried:
+		assert.Equal(t, "1s2", stackID)
+	case <-time.After(time.Second):
+		assert.Fail(t, "retry didn't run")
+	}
+
+	select {
+	case stackID := <-retried:
+		assert.Fail(t, "cancelled retry ran", stackID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Empty(t, s.pending)
+}
+
+func TestRetryStop(t *testing.T) {
+	s := newRetryScheduler(time.Hour, time.Hour)
+
+	retried := make(chan string, 2)
+	s.schedule("1s1", 1, func() {
+		retried <- "1s1"
+	})
+	s.stop()
+	assert.Equal(t, "1s1", <-retried)
+	assert.Empty(t, s.pending)
+
+	s.schedule("1s2", 1, func() {
+		retried <- "1s2"
+	})
+	select {
+	case stackID := <-retried:
+		assert.Equal(t, "1s2", stackID)
+	case <-time.After(time.Second):
+		assert.Fail(t, "retry after stop didn't run")
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// interruptTimeout bounds the time interrupted events take to publish why
+// they stopped
+const interruptTimeout = 3 * time.Second
+
+// Shutdown stops handling new events and waits for the events being handled
+// until ctx is done. The events still running then are interrupted, as are
+// the events waiting to be retried. Interrupted events publish a
+// transitioning message and aren't replied to, so that Rancher delivers
+// them again.
+func Shutdown(ctx context.Context) {
+	stacks.close()
+	retries.stop()
+
+	if stacks.wait(ctx) {
+		return
+	}
+
+	logrus.Infof("Interrupting the events of %d stacks", stacks.interrupt())
+
+	ctx, cancel := context.WithTimeout(context.Background(), interruptTimeout)
+	defer cancel()
+	if !stacks.wait(ctx) {
+		logrus.Warn("Interrupted events did not stop in time")
+	}
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rancher/rancher-compose-executor/composinator"
+)
+
+// subscription tracks whether the event router is subscribed to events
+type subscription struct {
+	sync.Mutex
+	subscribed bool
+}
+
+func (s *subscription) set(subscribed bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.subscribed = subscribed
+}
+
+func (s *subscription) get() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.subscribed
+}
+
+func (s *subscription) check() error {
+	if !s.get() {
+		return errors.New("Not subscribed to events")
+	}
+	return nil
+}
+
+// apiCheck checks that the Rancher API answers the credentials of the
+// executor
+func apiCheck(url, accessKey, secretKey string) composinator.Check {
+	httpClient := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+	return func() error {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(accessKey, secretKey)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("Rancher API answered %s", resp.Status)
+		}
+		return nil
+	}
+}
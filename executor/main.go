@@ -1,17 +1,44 @@
 package executor
 
 import (
+	"context"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/rancher/event-subscriber/events"
 	"github.com/rancher/go-rancher/v3"
 	"github.com/rancher/rancher-compose-executor/composinator"
 	"github.com/rancher/rancher-compose-executor/executor/handlers"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/metrics"
+	"github.com/rancher/rancher-compose-executor/redact"
+	"github.com/rancher/rancher-compose-executor/template"
 	"github.com/rancher/rancher-compose-executor/version"
 )
 
+// shutdownGracePeriod is how long the events being handled have to finish
+// once the executor is asked to stop. With the time interrupted events take
+// to report, the default stays within the 10 seconds docker stop waits
+// before killing the container. Raising SHUTDOWN_GRACE_PERIOD calls for a
+// longer stop timeout too.
+var shutdownGracePeriod = 5 * time.Second
+
 func Main() {
+	if err := logging.SetFormat(os.Getenv("LOG_FORMAT")); err != nil {
+		logrus.Fatal(err)
+	}
+	redact.RegisterCredentials(os.Getenv("CATTLE_ACCESS_KEY"), os.Getenv("CATTLE_SECRET_KEY"))
+
+	// The Rancher clients use the default transport
+	http.DefaultTransport = &metrics.Transport{
+		RoundTripper: http.DefaultTransport,
+	}
+
 	logger := logrus.WithFields(logrus.Fields{
 		"version": version.VERSION,
 	})
@@ -26,15 +53,43 @@ func Main() {
 
 	logger.Info("Starting rancher-compose-executor")
 
+	if timeout := os.Getenv("CLUSTER_READY_TIMEOUT"); timeout != "" {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			logrus.WithField("error", err).Fatal("Invalid CLUSTER_READY_TIMEOUT")
+		}
+		handlers.ClusterReadyTimeout = duration
+	}
+
+	if grace := os.Getenv("SHUTDOWN_GRACE_PERIOD"); grace != "" {
+		duration, err := time.ParseDuration(grace)
+		if err != nil {
+			logrus.WithField("error", err).Fatal("Invalid SHUTDOWN_GRACE_PERIOD")
+		}
+		shutdownGracePeriod = duration
+	}
+
+	if catalogs := os.Getenv("TRUSTED_CATALOGS"); catalogs != "" {
+		template.TrustedCatalogs = strings.Split(catalogs, ",")
+	}
+
 	eventHandlers := map[string]events.EventHandler{
-		"stack.create": handlers.WithTimeout(handlers.CreateStack),
-		"stack.update": handlers.WithTimeout(handlers.UpdateStack),
-		"stack.remove": handlers.WithTimeout(handlers.DeleteStack),
+		"stack.create":        handlers.WithTimeout(handlers.CreateStack),
+		"stack.update":        handlers.WithTimeout(handlers.UpdateStack),
+		"stack.remove":        handlers.WithTimeout(handlers.DeleteStack),
+		"stack.rollback":      handlers.WithTimeout(handlers.RollbackStack),
+		"stack.finishupgrade": handlers.WithTimeout(handlers.FinishUpgradeStack),
+		"stack.activate":      handlers.WithTimeout(handlers.ActivateStack),
+		"stack.deactivate":    handlers.WithTimeout(handlers.DeactivateStack),
 		"ping": func(event *events.Event, apiClient *client.RancherClient) error {
 			return nil
 		},
 	}
 
+	for name := range eventHandlers {
+		metrics.InitEvent(name)
+	}
+
 	url := os.Getenv("CATTLE_URL")
 	if url == "" {
 		url = "http://localhost:8080/v3"
@@ -49,9 +104,47 @@ func Main() {
 		logrus.WithField("error", err).Fatal("Unable to create event router")
 	}
 
-	if err := router.Start(nil); err != nil {
+	subscribed := &subscription{}
+	composinator.AddReadinessCheck("events", subscribed.check)
+	composinator.AddReadinessCheck("api", apiCheck(url, os.Getenv("CATTLE_ACCESS_KEY"), os.Getenv("CATTLE_SECRET_KEY")))
+
+	ready := make(chan bool, 1)
+	go func() {
+		<-ready
+		subscribed.set(true)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-signals
+		if !subscribed.get() {
+			logger.Infof("Received %v, exiting rancher-compose-executor", sig)
+			os.Exit(0)
+		}
+		// Closing the event stream ends router.Start
+		logger.Infof("Received %v, no longer receiving events", sig)
+		subscribed.set(false)
+		router.Stop()
+
+		sig = <-signals
+		logger.Warnf("Received %v again, exiting without waiting for the events being handled", sig)
+		os.Exit(1)
+	}()
+
+	if err := router.Start(ready); err != nil {
 		logrus.WithField("error", err).Fatal("Unable to start event router")
 	}
+	subscribed.set(false)
+
+	logger.Infof("Waiting up to %v for the events being handled", shutdownGracePeriod)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	handlers.Shutdown(ctx)
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	composinator.StopServer(ctx)
+	cancel()
 
 	logger.Info("Exiting rancher-compose-executor")
 }
@@ -0,0 +1,709 @@
+// Package fakecattle is an in-process fake of the Cattle v3 API for tests.
+// Resources are kept in memory and go through the states Cattle would move
+// them through, one state each time a resource is reloaded.
+package fakecattle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+)
+
+const (
+	AccessKey = "fakeaccesskey"
+	SecretKey = "fakesecretkey"
+)
+
+// plurals maps the schemas served by the fake to their collection names
+var plurals = map[string]string{
+	"account":        "accounts",
+	"certificate":    "certificates",
+	"cluster":        "clusters",
+	"container":      "containers",
+	"host":           "hosts",
+	"hostTemplate":   "hostTemplates",
+	"publish":        "publishes",
+	"revision":       "revisions",
+	"secret":         "secrets",
+	"service":        "services",
+	"setting":        "settings",
+	"stack":          "stacks",
+	"volume":         "volumes",
+	"volumeTemplate": "volumeTemplates",
+}
+
+// ignoredFilters are query parameters of a list that aren't filters
+var ignoredFilters = map[string]bool{
+	"limit":   true,
+	"marker":  true,
+	"sort":    true,
+	"order":   true,
+	"include": true,
+}
+
+// Server serves the fake API. Create it with NewServer and Close it once
+// the test is done.
+type Server struct {
+	*httptest.Server
+
+	lock      sync.Mutex
+	nextId    int
+	resources map[string][]*resource
+	publishes []client.Publish
+}
+
+type resource struct {
+	data map[string]interface{}
+	// steps are the states the resource still goes through
+	steps []string
+	// previous holds the launch configs of the revision a service was
+	// upgraded from
+	previous map[string]interface{}
+}
+
+// NewServer starts a fake API with the settings the executor reads
+func NewServer() *Server {
+	s := &Server{
+		resources: map[string][]*resource{},
+	}
+	s.Server = httptest.NewServer(s)
+	s.Add("setting", map[string]interface{}{
+		"id":    "lb.instance.image",
+		"name":  "lb.instance.image",
+		"value": "rancher/lb-service-haproxy",
+	})
+	return s
+}
+
+// Client returns a client of the fake API
+func (s *Server) Client() (*client.RancherClient, error) {
+	return client.NewRancherClient(&client.ClientOpts{
+		Url:       s.URL + "/v3",
+		AccessKey: AccessKey,
+		SecretKey: SecretKey,
+	})
+}
+
+// Add stores a resource as is, in its final state unless it has one, and
+// returns its id
+func (s *Server) Add(schemaType string, obj interface{}) string {
+	data := map[string]interface{}{}
+	if err := convert(obj, &data); err != nil {
+		panic(err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.add(schemaType, data)
+	if _, ok := r.data["state"]; !ok {
+		r.data["state"] = finalState(schemaType)
+	}
+	return r.data["id"].(string)
+}
+
+// Get reads a resource into output, returning false if it doesn't exist
+func (s *Server) Get(schemaType, id string, output interface{}) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.find(schemaType, id)
+	if r == nil {
+		return false
+	}
+	if err := convert(s.render(schemaType, r), output); err != nil {
+		panic(err)
+	}
+	return true
+}
+
+// Find reads the resource with a name that hasn't been removed into output,
+// returning false if there is none
+func (s *Server) Find(schemaType, name string, output interface{}) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, r := range s.resources[schemaType] {
+		if r.data["name"] == name && r.data["removed"] == nil {
+			if err := convert(s.render(schemaType, r), output); err != nil {
+				panic(err)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of resources of a type that haven't been removed
+func (s *Server) Count(schemaType string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	for _, r := range s.resources[schemaType] {
+		if r.data["removed"] == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// SetState puts a resource in a state, such as an inactive service
+func (s *Server) SetState(schemaType, id, state string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r := s.find(schemaType, id); r != nil {
+		r.data["state"] = state
+		r.steps = nil
+	}
+}
+
+// Publishes returns the event replies published so far
+func (s *Server) Publishes() []client.Publish {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]client.Publish{}, s.publishes...)
+}
+
+func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if user, password, ok := req.BasicAuth(); !ok || user != AccessKey || password != SecretKey {
+		writeError(rw, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/v3"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "" || path == "schemas":
+		s.serveSchemas(rw, s.URL+"/v3/schemas")
+		return
+	case len(parts) == 3 && parts[0] == "projects" && parts[2] == "schemas":
+		s.serveSchemas(rw, s.URL+req.URL.Path)
+		return
+	}
+
+	schemaType := ""
+	for name, plural := range plurals {
+		if plural == parts[0] {
+			schemaType = name
+		}
+	}
+	if schemaType == "" || len(parts) > 2 {
+		writeError(rw, http.StatusNotFound, "Not found")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(parts) == 1 {
+		switch req.Method {
+		case http.MethodGet:
+			s.list(rw, req, schemaType)
+		case http.MethodPost:
+			s.create(rw, req, schemaType)
+		default:
+			writeError(rw, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return
+	}
+
+	r := s.find(schemaType, parts[1])
+	if r == nil {
+		writeError(rw, http.StatusNotFound, "Not found")
+		return
+	}
+
+	switch {
+	case req.Method == http.MethodGet:
+		r.advance()
+		writeJSON(rw, http.StatusOK, s.render(schemaType, r))
+	case req.Method == http.MethodPut:
+		s.update(rw, req, schemaType, r)
+	case req.Method == http.MethodDelete:
+		r.remove()
+		writeJSON(rw, http.StatusOK, s.render(schemaType, r))
+	case req.Method == http.MethodPost && req.URL.Query().Get("action") != "":
+		s.action(rw, req, schemaType, r, req.URL.Query().Get("action"))
+	default:
+		writeError(rw, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) serveSchemas(rw http.ResponseWriter, self string) {
+	schemas := client.Schemas{
+		Collection: client.Collection{
+			Type:         "collection",
+			ResourceType: "schema",
+		},
+	}
+	for name, plural := range plurals {
+		schemas.Data = append(schemas.Data, client.Schema{
+			Resource: client.Resource{
+				Id:   name,
+				Type: "schema",
+				Links: map[string]string{
+					"self":       s.URL + "/v3/schemas/" + name,
+					"collection": s.URL + "/v3/" + plural,
+				},
+			},
+			PluralName:        plural,
+			ResourceMethods:   []string{"GET", "PUT", "DELETE"},
+			CollectionMethods: []string{"GET", "POST"},
+		})
+	}
+	rw.Header().Set("X-API-Schemas", self)
+	writeJSON(rw, http.StatusOK, schemas)
+}
+
+func (s *Server) list(rw http.ResponseWriter, req *http.Request, schemaType string) {
+	data := []interface{}{}
+	for _, r := range s.resources[schemaType] {
+		if matches(r.data, req.URL.Query()) {
+			data = append(data, s.render(schemaType, r))
+		}
+	}
+	writeJSON(rw, http.StatusOK, map[string]interface{}{
+		"type":         "collection",
+		"resourceType": schemaType,
+		"data":         data,
+	})
+}
+
+func (s *Server) create(rw http.ResponseWriter, req *http.Request, schemaType string) {
+	data := map[string]interface{}{}
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	delete(data, "id")
+
+	if schemaType == "publish" {
+		var publish client.Publish
+		if err := convert(data, &publish); err != nil {
+			writeError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.publishes = append(s.publishes, publish)
+		writeJSON(rw, http.StatusCreated, data)
+		return
+	}
+
+	r := s.add(schemaType, data)
+	switch schemaType {
+	case "service":
+		if r.data["kind"] == nil {
+			r.data["kind"] = "service"
+		}
+		r.data["currentScale"] = r.data["scale"]
+		r.data["healthState"] = "healthy"
+		r.data["revisionId"] = s.addRevision(r.data)
+		r.transition("activating", "active")
+	case "container":
+		r.data["healthState"] = "healthy"
+		r.data["revisionId"] = s.addRevision(map[string]interface{}{
+			"launchConfig": copyMap(r.data),
+		})
+		r.transition("starting", "running")
+	default:
+		r.transition("activating", finalState(schemaType))
+	}
+	writeJSON(rw, http.StatusCreated, s.render(schemaType, r))
+}
+
+func (s *Server) update(rw http.ResponseWriter, req *http.Request, schemaType string, r *resource) {
+	updates := map[string]interface{}{}
+	if err := json.NewDecoder(req.Body).Decode(&updates); err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, key := range []string{"id", "type", "links", "actions", "state", "transitioning"} {
+		delete(updates, key)
+	}
+
+	if schemaType == "service" && launchConfigsChanged(r.data, updates) {
+		r.previous = map[string]interface{}{
+			"launchConfig":           r.data["launchConfig"],
+			"secondaryLaunchConfigs": r.data["secondaryLaunchConfigs"],
+			"revisionId":             r.data["revisionId"],
+		}
+		for k, v := range updates {
+			r.data[k] = v
+		}
+		clearForceUpgrade(r.data)
+		r.data["previousRevisionId"] = r.previous["revisionId"]
+		r.data["revisionId"] = s.addRevision(r.data)
+		r.transition("upgrading", "upgraded")
+	} else {
+		for k, v := range updates {
+			r.data[k] = v
+		}
+		clearForceUpgrade(r.data)
+	}
+	if _, ok := updates["scale"]; ok {
+		r.data["currentScale"] = updates["scale"]
+	}
+
+	writeJSON(rw, http.StatusOK, s.render(schemaType, r))
+}
+
+func (s *Server) action(rw http.ResponseWriter, req *http.Request, schemaType string, r *resource, action string) {
+	if _, ok := actions(schemaType, r)[action]; !ok {
+		writeError(rw, http.StatusUnprocessableEntity, fmt.Sprintf("Action %s not available in state %v", action, r.data["state"]))
+		return
+	}
+
+	switch action {
+	case "remove":
+		r.remove()
+	case "activate":
+		if r.data["state"] == "paused" {
+			r.transition("upgrading", "upgraded")
+		} else {
+			r.transition("activating", "active")
+		}
+	case "deactivate":
+		r.transition("deactivating", "inactive")
+	case "pause":
+		r.transition("pausing", "paused")
+	case "finishupgrade":
+		r.transition("finishing-upgrade", "active")
+	case "rollback":
+		for k, v := range r.previous {
+			r.data[k] = v
+		}
+		r.previous = nil
+		r.data["previousRevisionId"] = nil
+		r.transition("rolling-back", "active")
+	case "restart":
+		if schemaType == "container" {
+			r.transition("restarting", "running")
+		} else {
+			r.transition("restarting", "active")
+		}
+	case "start":
+		r.transition("starting", "running")
+	case "stop":
+		r.transition("stopping", "stopped")
+	case "upgrade":
+		s.upgradeContainer(rw, req, r)
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, s.render(schemaType, r))
+}
+
+// upgradeContainer replaces the container by one of a new revision if the
+// config changes anything, and returns the revision the container is at
+func (s *Server) upgradeContainer(rw http.ResponseWriter, req *http.Request, r *resource) {
+	var input struct {
+		Config map[string]interface{} `json:"config"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	changed := false
+	for k, v := range input.Config {
+		if k != "metadata" && !reflect.DeepEqual(r.data[k], v) {
+			changed = true
+		}
+	}
+	if !changed {
+		s.renderRevision(rw, r.data["revisionId"])
+		return
+	}
+
+	upgraded := copyMap(r.data)
+	for k, v := range input.Config {
+		upgraded[k] = v
+	}
+	r.remove()
+
+	next := s.add("container", upgraded)
+	next.data["previousRevisionId"] = r.data["revisionId"]
+	next.data["revisionId"] = s.addRevision(map[string]interface{}{
+		"launchConfig": copyMap(input.Config),
+	})
+	next.transition("starting", "running")
+	s.renderRevision(rw, next.data["revisionId"])
+}
+
+func (s *Server) renderRevision(rw http.ResponseWriter, id interface{}) {
+	revision := s.find("revision", fmt.Sprint(id))
+	if revision == nil {
+		writeError(rw, http.StatusNotFound, "Not found")
+		return
+	}
+	writeJSON(rw, http.StatusOK, s.render("revision", revision))
+}
+
+// addRevision records the launch configs of a service or container as a
+// revision and returns its id
+func (s *Server) addRevision(config map[string]interface{}) string {
+	revision := s.add("revision", map[string]interface{}{
+		"config": map[string]interface{}{
+			"launchConfig":           config["launchConfig"],
+			"secondaryLaunchConfigs": config["secondaryLaunchConfigs"],
+		},
+	})
+	revision.data["state"] = finalState("revision")
+	return revision.data["id"].(string)
+}
+
+func (s *Server) add(schemaType string, data map[string]interface{}) *resource {
+	if id, _ := data["id"].(string); id == "" {
+		s.nextId++
+		data["id"] = fmt.Sprintf("1%s%d", idPrefix(schemaType), s.nextId)
+	}
+	data["type"] = schemaType
+	r := &resource{
+		data: data,
+	}
+	s.resources[schemaType] = append(s.resources[schemaType], r)
+	return r
+}
+
+func (s *Server) find(schemaType, id string) *resource {
+	for _, r := range s.resources[schemaType] {
+		if r.data["id"] == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// render returns the resource as the API would, with its links, actions and
+// transitioning state
+func (s *Server) render(schemaType string, r *resource) map[string]interface{} {
+	data := copyMap(r.data)
+	self := fmt.Sprintf("%s/v3/%s/%s", s.URL, plurals[schemaType], data["id"])
+	data["links"] = map[string]string{
+		"self": self,
+	}
+	resourceActions := map[string]string{}
+	for action := range actions(schemaType, r) {
+		resourceActions[action] = self + "?action=" + action
+	}
+	data["actions"] = resourceActions
+	if len(r.steps) > 0 {
+		data["transitioning"] = "yes"
+	} else {
+		data["transitioning"] = "no"
+	}
+	return data
+}
+
+// transition moves the resource into a transitioning state, which it leaves
+// for the final state on the next reload
+func (r *resource) transition(transitioning, final string) {
+	r.data["state"] = transitioning
+	r.steps = []string{final}
+}
+
+func (r *resource) remove() {
+	r.data["removed"] = time.Now().UTC().Format(time.RFC3339)
+	r.transition("removing", "removed")
+}
+
+func (r *resource) advance() {
+	if len(r.steps) == 0 {
+		return
+	}
+	r.data["state"] = r.steps[0]
+	r.steps = r.steps[1:]
+}
+
+// actions returns the actions available on a resource in its current state
+func actions(schemaType string, r *resource) map[string]bool {
+	result := map[string]bool{}
+	if len(r.steps) > 0 || r.data["removed"] != nil {
+		return result
+	}
+
+	state := r.data["state"]
+	switch schemaType {
+	case "service":
+		switch state {
+		case "active":
+			result["deactivate"] = true
+			result["restart"] = true
+		case "inactive":
+			result["activate"] = true
+		case "upgraded":
+			result["finishupgrade"] = true
+		case "upgrading":
+			result["pause"] = true
+		case "paused":
+			result["activate"] = true
+		}
+		if r.previous != nil {
+			result["rollback"] = true
+		}
+	case "container":
+		switch state {
+		case "running":
+			result["stop"] = true
+			result["restart"] = true
+		case "stopped":
+			result["start"] = true
+		}
+		result["upgrade"] = true
+	}
+	result["remove"] = true
+	return result
+}
+
+func finalState(schemaType string) string {
+	if schemaType == "container" {
+		return "running"
+	}
+	return "active"
+}
+
+func idPrefix(schemaType string) string {
+	switch schemaType {
+	case "container":
+		return "i"
+	case "stack":
+		return "st"
+	}
+	return schemaType[:1]
+}
+
+// matches applies the filters of a list, such as name=web or removed_null
+func matches(data map[string]interface{}, filters map[string][]string) bool {
+	for key, values := range filters {
+		if ignoredFilters[key] || len(values) == 0 {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(key, "_null"):
+			if value(data, strings.TrimSuffix(key, "_null")) != "" {
+				return false
+			}
+		case strings.HasSuffix(key, "_notnull"):
+			if value(data, strings.TrimSuffix(key, "_notnull")) == "" {
+				return false
+			}
+		case strings.HasSuffix(key, "_ne"):
+			if value(data, strings.TrimSuffix(key, "_ne")) == values[0] {
+				return false
+			}
+		default:
+			if value(data, key) != values[0] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func value(data map[string]interface{}, key string) string {
+	if data[key] == nil {
+		return ""
+	}
+	return fmt.Sprint(data[key])
+}
+
+// launchConfigsChanged tells whether updating a service creates a new
+// revision, which it does when the launch configs change or an upgrade is
+// forced
+func launchConfigsChanged(existing, updates map[string]interface{}) bool {
+	changed := false
+	for _, key := range []string{"launchConfig", "secondaryLaunchConfigs"} {
+		update, ok := updates[key]
+		if !ok {
+			continue
+		}
+		if forced(update) || !reflect.DeepEqual(normalize(existing[key]), normalize(update)) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+func forced(launchConfigs interface{}) bool {
+	switch v := launchConfigs.(type) {
+	case map[string]interface{}:
+		return v["forceUpgrade"] == true
+	case []interface{}:
+		for _, lc := range v {
+			if forced(lc) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalize drops the fields of launch configs that only steer the update
+func normalize(launchConfigs interface{}) interface{} {
+	switch v := launchConfigs.(type) {
+	case map[string]interface{}:
+		result := copyMap(v)
+		delete(result, "completeUpdate")
+		delete(result, "forceUpgrade")
+		return result
+	case []interface{}:
+		result := []interface{}{}
+		for _, lc := range v {
+			result = append(result, normalize(lc))
+		}
+		return result
+	}
+	return launchConfigs
+}
+
+func clearForceUpgrade(data map[string]interface{}) {
+	if lc, ok := data["launchConfig"].(map[string]interface{}); ok {
+		delete(lc, "forceUpgrade")
+	}
+	if lcs, ok := data["secondaryLaunchConfigs"].([]interface{}); ok {
+		for _, lc := range lcs {
+			if lc, ok := lc.(map[string]interface{}); ok {
+				delete(lc, "forceUpgrade")
+			}
+		}
+	}
+}
+
+func copyMap(data map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, v := range data {
+		result[k] = v
+	}
+	return result
+}
+
+func convert(from, to interface{}) error {
+	bytes, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, to)
+}
+
+func writeJSON(rw http.ResponseWriter, status int, obj interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(obj)
+}
+
+func writeError(rw http.ResponseWriter, status int, message string) {
+	writeJSON(rw, status, map[string]interface{}{
+		"type":    "error",
+		"status":  status,
+		"message": message,
+	})
+}
@@ -0,0 +1,113 @@
+package fakecattle
+
+import (
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceTransitions(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	c, err := s.Client()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	service, err := c.Service.Create(&client.Service{
+		Name:  "web",
+		Scale: 2,
+		LaunchConfig: &client.LaunchConfig{
+			Image: "nginx",
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "activating", service.State)
+	assert.Equal(t, "yes", service.Transitioning)
+
+	assert.NoError(t, c.Reload(&service.Resource, service))
+	assert.Equal(t, "active", service.State)
+	assert.Equal(t, "no", service.Transitioning)
+	assert.Equal(t, int64(2), service.CurrentScale)
+
+	unchanged, err := c.Service.Update(service, &client.Service{
+		LaunchConfig: &client.LaunchConfig{
+			Image: "nginx",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, service.RevisionId, unchanged.RevisionId)
+	assert.Equal(t, "active", unchanged.State)
+
+	upgraded, err := c.Service.Update(service, &client.Service{
+		LaunchConfig: &client.LaunchConfig{
+			Image: "nginx:1.13",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "upgrading", upgraded.State)
+	assert.Equal(t, service.RevisionId, upgraded.PreviousRevisionId)
+
+	assert.NoError(t, c.Reload(&upgraded.Resource, upgraded))
+	assert.Equal(t, "upgraded", upgraded.State)
+
+	rolledBack, err := c.Service.ActionRollback(upgraded, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "rolling-back", rolledBack.State)
+	assert.NoError(t, c.Reload(&rolledBack.Resource, rolledBack))
+	assert.Equal(t, "active", rolledBack.State)
+	assert.Equal(t, "nginx", rolledBack.LaunchConfig.Image)
+	assert.Equal(t, service.RevisionId, rolledBack.RevisionId)
+
+	_, err = c.Service.ActionActivate(rolledBack)
+	assert.Error(t, err)
+}
+
+func TestListFilters(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	stackId := s.Add("stack", client.Stack{Name: "test"})
+	s.Add("service", client.Service{Name: "web", StackId: stackId})
+	s.Add("service", client.Service{Name: "db", StackId: stackId})
+	s.Add("service", client.Service{Name: "web", StackId: "other"})
+	s.Add("service", client.Service{Name: "old", StackId: stackId, Removed: "2017-01-01T00:00:00Z"})
+
+	c, err := s.Client()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	services, err := c.Service.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"stackId":      stackId,
+			"removed_null": nil,
+		},
+	})
+	if assert.NoError(t, err) {
+		assert.Len(t, services.Data, 2)
+	}
+
+	services, err = c.Service.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"name":    "web",
+			"stackId": stackId,
+		},
+	})
+	if assert.NoError(t, err) && assert.Len(t, services.Data, 1) {
+		assert.Equal(t, "active", services.Data[0].State)
+	}
+
+	setting, err := c.Setting.ById("lb.instance.image")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "rancher/lb-service-haproxy", setting.Value)
+	}
+
+	missing, err := c.Service.ById("1s999")
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 
 	"bytes"
-	log "github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/redact"
+	"golang.org/x/net/context"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"net/url"
@@ -47,6 +49,9 @@ func GetNamespaceName(rancherClient *client.RancherClient, stack *client.Stack)
 	if err != nil {
 		return "", err
 	}
+	if account == nil {
+		return "", fmt.Errorf("Failed to find account %s", stack.AccountId)
+	}
 	return account.ExternalId, nil
 }
 
@@ -55,6 +60,7 @@ func CreateKubeconfig(endpoint, token string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	redact.Register(token)
 	kubeconfigContents := []byte(fmt.Sprintf(kubeconfigTemplate, endpoint, token))
 	if _, err := kubeconfig.Write(kubeconfigContents); err != nil {
 		return "", err
@@ -65,7 +71,9 @@ func CreateKubeconfig(endpoint, token string) (string, error) {
 	return kubeconfig.Name(), nil
 }
 
-func Apply(kubeconfigLocation, name, namespace string, resource interface{}) error {
+func Apply(ctx context.Context, kubeconfigLocation, name, namespace string, resource interface{}) error {
+	// kubectl may echo the manifest when it fails
+	redact.RegisterManifest(resource)
 	resourceBytes, err := yaml.Marshal(resource)
 	if err != nil {
 		return err
@@ -74,14 +82,15 @@ func Apply(kubeconfigLocation, name, namespace string, resource interface{}) err
 	cmd := exec.Command("kubectl", "--kubeconfig", kubeconfigLocation, "-n", namespace, "apply", "-f", "-")
 	cmd.Stdin = bytes.NewReader(resourceBytes)
 
-	log.Infof("Applying Kubernetes resource %s", name)
+	logging.FromContext(ctx).Infof("Applying Kubernetes resource %s", name)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("Failed to apply Kubernetes resource %s: %v (%s)", name, err, output)
+		return fmt.Errorf("Failed to apply Kubernetes resource %s: %v (%s)", name, err, redact.String(string(output)))
 	}
 	return nil
 }
 
-func Delete(kubeconfigLocation, name, namespace string, resource interface{}) error {
+func Delete(ctx context.Context, kubeconfigLocation, name, namespace string, resource interface{}) error {
+	redact.RegisterManifest(resource)
 	resourceBytes, err := yaml.Marshal(resource)
 	if err != nil {
 		return err
@@ -90,9 +99,9 @@ func Delete(kubeconfigLocation, name, namespace string, resource interface{}) er
 	cmd := exec.Command("kubectl", "--kubeconfig", kubeconfigLocation, "-n", namespace, "delete", "-f", "-")
 	cmd.Stdin = bytes.NewReader(resourceBytes)
 
-	log.Infof("Deleting Kubernetes resource %s", name)
+	logging.FromContext(ctx).Infof("Deleting Kubernetes resource %s", name)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("Failed to delete Kubernetes resource %s: %v (%s)", name, err, output)
+		return fmt.Errorf("Failed to delete Kubernetes resource %s: %v (%s)", name, err, redact.String(string(output)))
 	}
 	return nil
 }
@@ -0,0 +1,68 @@
+// Package logging carries a logger through context.Context, so that every
+// line logged while handling an event tells which stack, event, service and
+// phase it belongs to.
+package logging
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-compose-executor/redact"
+	"golang.org/x/net/context"
+)
+
+// Fields set on the logger of a context
+const (
+	StackName = "stackName"
+	StackID   = "stackId"
+	EventID   = "eventId"
+	Event     = "event"
+	Service   = "service"
+	Phase     = "phase"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying the logger
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// WithFields returns a context whose logger logs the fields too
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return WithLogger(ctx, FromContext(ctx).WithFields(fields))
+}
+
+// WithField returns a context whose logger logs the field too
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	return WithLogger(ctx, FromContext(ctx).WithField(key, value))
+}
+
+// FromContext returns the logger of the context, or the standard logger if
+// there is none
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+			return logger
+		}
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// SetFormat makes the standard logger log in the format, text or json. The
+// output is redacted either way.
+func SetFormat(format string) error {
+	var formatter logrus.Formatter
+	switch format {
+	case "", "text":
+		formatter = &logrus.TextFormatter{}
+	case "json":
+		formatter = &logrus.JSONFormatter{}
+	default:
+		return fmt.Errorf("Unknown log format %s, expected text or json", format)
+	}
+	logrus.SetFormatter(&redact.Formatter{
+		Formatter: formatter,
+	})
+	return nil
+}
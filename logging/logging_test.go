@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-compose-executor/redact"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestFromContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()).Data)
+
+	ctx := WithFields(context.Background(), logrus.Fields{
+		StackID: "1st1",
+		EventID: "1e1",
+	})
+	ctx = WithField(ctx, Service, "web")
+	assert.Equal(t, logrus.Fields{
+		StackID: "1st1",
+		EventID: "1e1",
+		Service: "web",
+	}, FromContext(ctx).Data)
+
+	// Fields added further down don't leak up
+	WithField(ctx, Phase, "up")
+	assert.NotContains(t, FromContext(ctx).Data, Phase)
+}
+
+func TestSetFormat(t *testing.T) {
+	defer logrus.SetOutput(logrus.StandardLogger().Out)
+	defer logrus.SetFormatter(logrus.StandardLogger().Formatter)
+
+	redact.Register("logging-s3cr3t", "p<ss&w0rd")
+	buf := &bytes.Buffer{}
+	logrus.SetOutput(buf)
+
+	assert.NoError(t, SetFormat("json"))
+	ctx := WithFields(context.Background(), logrus.Fields{
+		StackName: "wordpress",
+		Phase:     "create",
+	})
+	FromContext(ctx).WithField("password", "p<ss&w0rd").Infof("Creating secret with logging-s3cr3t")
+
+	line := map[string]interface{}{}
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &line)) {
+		assert.Equal(t, "wordpress", line[StackName])
+		assert.Equal(t, "create", line[Phase])
+		assert.Equal(t, "Creating secret with "+redact.Mask, line["msg"])
+		// The JSON formatter escapes < and &, which must not keep the
+		// value from being masked
+		assert.Equal(t, redact.Mask, line["password"])
+	}
+
+	assert.NoError(t, SetFormat("text"))
+	assert.Error(t, SetFormat("xml"))
+}
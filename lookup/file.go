@@ -0,0 +1,88 @@
+package lookup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileResourceLookup reads files from disk below Root. Paths are resolved
+// relative to the file referencing them, see Resolve. With Restrict set,
+// as for catalog content, files outside of Root can't be read.
+type FileResourceLookup struct {
+	Root     string
+	Restrict bool
+}
+
+func (f *FileResourceLookup) Lookup(file, relativeTo string) ([]byte, string, error) {
+	resolved := Resolve(file, relativeTo)
+	if f.Restrict && outsideRoot(resolved) {
+		return nil, resolved, fmt.Errorf("%s is outside of the project directory", file)
+	}
+
+	fullPath := filepath.FromSlash(resolved)
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(f.root(), fullPath)
+	}
+
+	if f.Restrict {
+		if err := f.checkInRoot(fullPath); err != nil {
+			return nil, resolved, err
+		}
+	}
+
+	content, err := ioutil.ReadFile(fullPath)
+	return content, resolved, err
+}
+
+func (f *FileResourceLookup) root() string {
+	if f.Root == "" {
+		return "."
+	}
+	return f.Root
+}
+
+// checkInRoot makes sure symlinks don't lead outside of the root
+func (f *FileResourceLookup) checkInRoot(fullPath string) error {
+	root, err := filepath.EvalSymlinks(f.root())
+	if err != nil {
+		return err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	target, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return err
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || outsideRoot(filepath.ToSlash(rel)) {
+		return fmt.Errorf("%s is outside of the project directory", fullPath)
+	}
+	return nil
+}
+
+// Resolve returns the path of file as referenced from the file relativeTo.
+// Relative paths are resolved from the directory of relativeTo, a file name
+// or a directory ending in "/".
+func Resolve(file, relativeTo string) string {
+	if path.IsAbs(file) {
+		return path.Clean(file)
+	}
+	dir := relativeTo
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+	return path.Join(dir, file)
+}
+
+func outsideRoot(resolved string) bool {
+	return path.IsAbs(resolved) || resolved == ".." || strings.HasPrefix(resolved, "../")
+}
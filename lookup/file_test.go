@@ -0,0 +1,76 @@
+package lookup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	assert.Equal(t, "common.yml", Resolve("common.yml", "docker-compose.yml"))
+	assert.Equal(t, "common.yml", Resolve("./common.yml", "./"))
+	assert.Equal(t, "common.yml", Resolve("common.yml", ""))
+	assert.Equal(t, "sub/env", Resolve("env", "sub/docker-compose.yml"))
+	assert.Equal(t, "env", Resolve("../env", "sub/docker-compose.yml"))
+	assert.Equal(t, "../env", Resolve("../env", "docker-compose.yml"))
+	assert.Equal(t, "/etc/passwd", Resolve("/etc/../etc/passwd", "docker-compose.yml"))
+}
+
+func TestFileResourceLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lookup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "project")
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "sub"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "sub", "env"), []byte("A=1"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "secret"), []byte("s3cr3t"), 0644))
+	assert.Nil(t, os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "link")))
+
+	l := &FileResourceLookup{Root: root}
+	content, resolved, err := l.Lookup("env", "sub/docker-compose.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, "sub/env", resolved)
+	assert.Equal(t, "A=1", string(content))
+
+	content, _, err = l.Lookup("../secret", "docker-compose.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cr3t", string(content))
+
+	l.Restrict = true
+	_, _, err = l.Lookup("env", "sub/docker-compose.yml")
+	assert.Nil(t, err)
+	_, _, err = l.Lookup("../secret", "docker-compose.yml")
+	assert.NotNil(t, err)
+	_, _, err = l.Lookup(filepath.Join(dir, "secret"), "docker-compose.yml")
+	assert.NotNil(t, err)
+	_, _, err = l.Lookup("link", "docker-compose.yml")
+	assert.NotNil(t, err)
+}
+
+func TestMemoryResourceLookup(t *testing.T) {
+	l := &MemoryResourceLookup{
+		Content: map[string][]byte{
+			"common.yml":     []byte("common"),
+			"sub/common.yml": []byte("sub"),
+		},
+	}
+
+	content, _, err := l.Lookup("common.yml", "docker-compose.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, "common", string(content))
+
+	content, _, err = l.Lookup("common.yml", "sub/docker-compose.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, "sub", string(content))
+
+	content, _, err = l.Lookup("/common.yml", "sub/docker-compose.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, "common", string(content))
+
+	_, _, err = l.Lookup("../common.yml", "docker-compose.yml")
+	assert.NotNil(t, err)
+}
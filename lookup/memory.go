@@ -5,17 +5,20 @@ import (
 	"strings"
 )
 
+// MemoryResourceLookup serves the files of a catalog template from memory.
+// Absolute paths are taken relative to the template and paths leading out
+// of it are rejected.
 type MemoryResourceLookup struct {
 	Content map[string][]byte
 }
 
 func (m *MemoryResourceLookup) Lookup(file, relativeTo string) ([]byte, string, error) {
-	file = strings.TrimPrefix(file, "/")
-	relativeTo = strings.TrimSuffix(relativeTo, "/")
-	finalFile := relativeTo + "/" + file
-
-	if relativeTo == "." {
-		finalFile = file
+	finalFile := Resolve(file, relativeTo)
+	if strings.HasPrefix(file, "/") {
+		finalFile = strings.TrimPrefix(finalFile, "/")
+	}
+	if outsideRoot(finalFile) {
+		return nil, finalFile, fmt.Errorf("%s is outside of the template", file)
 	}
 
 	if content, ok := m.Content[finalFile]; ok {
@@ -0,0 +1,119 @@
+package lookup
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rancher/go-rancher/v3"
+)
+
+// Reference is a server side resource a template refers to by name
+type Reference struct {
+	Kind string `json:"kind" yaml:"kind"`
+	Name string `json:"name" yaml:"name"`
+	Id   string `json:"id,omitempty" yaml:"id,omitempty"`
+}
+
+// ReferenceLookup records the server side resources looked up through it.
+// References are resolved through Resolver, or without one to placeholder
+// resources so that payloads can be generated without a server.
+type ReferenceLookup struct {
+	Resolver ServerResourceLookup
+
+	lock       sync.Mutex
+	references []Reference
+}
+
+func (r *ReferenceLookup) Service(name string) (*client.Service, error) {
+	if r.Resolver == nil {
+		return &client.Service{
+			Resource: client.Resource{Id: r.record("service", name, "")},
+			Name:     name,
+		}, nil
+	}
+	service, err := r.Resolver.Service(name)
+	if service != nil {
+		r.record("service", name, service.Id)
+	} else {
+		r.record("service", name, "")
+	}
+	return service, err
+}
+
+func (r *ReferenceLookup) Container(name string) (*client.Container, error) {
+	if r.Resolver == nil {
+		return &client.Container{
+			Resource: client.Resource{Id: r.record("container", name, "")},
+			Name:     name,
+		}, nil
+	}
+	container, err := r.Resolver.Container(name)
+	if container != nil {
+		r.record("container", name, container.Id)
+	} else {
+		r.record("container", name, "")
+	}
+	return container, err
+}
+
+func (r *ReferenceLookup) Cert(name string) (*client.Certificate, error) {
+	if r.Resolver == nil {
+		return &client.Certificate{
+			Resource: client.Resource{Id: r.record("certificate", name, "")},
+			Name:     name,
+		}, nil
+	}
+	cert, err := r.Resolver.Cert(name)
+	if cert != nil {
+		r.record("certificate", name, cert.Id)
+	} else {
+		r.record("certificate", name, "")
+	}
+	return cert, err
+}
+
+func (r *ReferenceLookup) Secret(name string) (*client.Secret, error) {
+	if r.Resolver == nil {
+		return &client.Secret{
+			Resource: client.Resource{Id: r.record("secret", name, "")},
+			Name:     name,
+		}, nil
+	}
+	secret, err := r.Resolver.Secret(name)
+	if secret != nil {
+		r.record("secret", name, secret.Id)
+	} else {
+		r.record("secret", name, "")
+	}
+	return secret, err
+}
+
+// References returns every resource looked up so far, once each
+func (r *ReferenceLookup) References() []Reference {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Reference{}, r.references...)
+}
+
+// record adds a reference and returns its id, a placeholder when it isn't
+// resolved
+func (r *ReferenceLookup) record(kind, name, id string) string {
+	if id == "" && r.Resolver == nil {
+		id = fmt.Sprintf("${%s:%s}", kind, name)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, reference := range r.references {
+		if reference.Kind == kind && reference.Name == name {
+			return id
+		}
+	}
+	r.references = append(r.references, Reference{
+		Kind: kind,
+		Name: name,
+		Id:   id,
+	})
+	return id
+}
@@ -0,0 +1,32 @@
+package lookup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferenceLookupPlaceholders(t *testing.T) {
+	r := &ReferenceLookup{}
+
+	service, err := r.Service("web")
+	assert.NoError(t, err)
+	assert.Equal(t, "${service:web}", service.Id)
+
+	cert, err := r.Cert("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "${certificate:example.com}", cert.Id)
+
+	secret, err := r.Secret("password")
+	assert.NoError(t, err)
+	assert.Equal(t, "${secret:password}", secret.Id)
+
+	_, err = r.Service("web")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Reference{
+		{Kind: "service", Name: "web", Id: "${service:web}"},
+		{Kind: "certificate", Name: "example.com", Id: "${certificate:example.com}"},
+		{Kind: "secret", Name: "password", Id: "${secret:password}"},
+	}, r.References())
+}
@@ -6,4 +6,5 @@ type ServerResourceLookup interface {
 	Service(name string) (*client.Service, error)
 	Container(name string) (*client.Container, error)
 	Cert(name string) (*client.Certificate, error)
+	Secret(name string) (*client.Secret, error)
 }
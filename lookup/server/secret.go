@@ -0,0 +1,22 @@
+package server
+
+import (
+	"github.com/rancher/go-rancher/v3"
+)
+
+func (r *RancherServerLookup) Secret(name string) (*client.Secret, error) {
+	secrets, err := r.c.Secret.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"name": name,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secrets.Data) == 0 {
+		return nil, nil
+	}
+
+	return &secrets.Data[0], nil
+}
@@ -1,12 +1,13 @@
 package server
 
 import (
-	log "github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
 )
 
 func (r *RancherServerLookup) Service(name string) (*client.Service, error) {
-	log.Debugf("Finding service %s", name)
+	logger := r.logger.WithField(logging.Service, name)
+	logger.Debugf("Finding service %s", name)
 
 	name, stackId, err := resolveNameAndStackId(r.c, r.stackID, name)
 	if err != nil {
@@ -29,6 +30,6 @@ func (r *RancherServerLookup) Service(name string) (*client.Service, error) {
 		return nil, nil
 	}
 
-	log.Debugf("Found service %s", name)
+	logger.Debugf("Found service %s", name)
 	return &services.Data[0], nil
 }
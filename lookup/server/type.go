@@ -4,18 +4,25 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"golang.org/x/net/context"
 )
 
 type RancherServerLookup struct {
 	stackID string
 	c       *client.RancherClient
+	logger  *logrus.Entry
 }
 
-func NewLookup(stackID string, client *client.RancherClient) *RancherServerLookup {
+// NewLookup looks up the resources of the stack, logging with the logger
+// of ctx
+func NewLookup(ctx context.Context, stackID string, client *client.RancherClient) *RancherServerLookup {
 	return &RancherServerLookup{
 		stackID: stackID,
 		c:       client,
+		logger:  logging.FromContext(ctx).WithField(logging.StackID, stackID),
 	}
 }
 
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const prefix = "rancher_compose_executor_"
+
+var (
+	durationBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600}
+	apiBuckets      = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	EventsReceived        = NewCounter(prefix+"events_received_total", "Events received, by event type.", "event")
+	EventsSucceeded       = NewCounter(prefix+"events_succeeded_total", "Events handled successfully, by event type.", "event")
+	EventsFailed          = NewCounter(prefix+"events_failed_total", "Events that failed, by event type.", "event")
+	EventsTimedOut        = NewCounter(prefix+"events_timed_out_total", "Events that timed out, by event type.", "event")
+	EventsClusterNotReady = NewCounter(prefix+"events_cluster_not_ready_total", "Attempts of events deferred as the cluster wasn't ready, by event type.", "event")
+	EventDuration         = NewHistogram(prefix+"event_duration_seconds", "Time taken to handle an attempt of an event, by event type.", durationBuckets, "event")
+
+	APIRequests        = NewCounter(prefix+"api_requests_total", "Rancher API requests, by resource type, method and status code.", "type", "method", "code")
+	APIRequestDuration = NewHistogram(prefix+"api_request_duration_seconds", "Latency of Rancher API requests, by resource type and method.", apiBuckets, "type", "method")
+
+	WaitPolls         = NewCounter(prefix+"wait_polls_total", "Reloads of resources waiting for them to finish transitioning, by resource type.", "type")
+	ImagePullDuration = NewHistogram(prefix+"image_pull_duration_seconds", "Time taken to pull images on the hosts.", durationBuckets)
+	InFlightStacks    = NewGauge(prefix+"in_flight_stacks", "Stacks with events being handled or queued.")
+
+	CacheHits    = NewCounter(prefix+"cache_hits_total", "Hits of the caches used to construct projects, by cache.", "cache")
+	CacheMisses  = NewCounter(prefix+"cache_misses_total", "Misses of the caches used to construct projects, by cache.", "cache")
+	CacheEntries = NewGauge(prefix+"cache_entries", "Entries of the caches used to construct projects, by cache.", "cache")
+)
+
+// InitEvent reports the metrics of an event type before it is first received
+func InitEvent(event string) {
+	for _, counter := range []*Counter{EventsReceived, EventsSucceeded, EventsFailed, EventsTimedOut, EventsClusterNotReady} {
+		counter.Init(event)
+	}
+	EventDuration.Init(event)
+}
+
+// Transport records the count and latency of the Rancher API requests made
+// through the transport it wraps
+type Transport struct {
+	http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.RoundTripper.RoundTrip(req)
+
+	resourceType := ResourceType(req.URL.Path)
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	APIRequests.Inc(resourceType, req.Method, code)
+	APIRequestDuration.Observe(time.Since(start).Seconds(), resourceType, req.Method)
+	return resp, err
+}
+
+// ResourceType returns the collection an API path refers to, such as
+// services for /v3/projects/1a5/services/1s1
+func ResourceType(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		if part != "v3" && part != "v1-catalog" {
+			continue
+		}
+		rest := parts[i+1:]
+		if len(rest) >= 2 && rest[0] == "projects" {
+			rest = rest[2:]
+		}
+		if len(rest) == 0 || rest[0] == "" {
+			return "root"
+		}
+		return rest[0]
+	}
+	return "other"
+}
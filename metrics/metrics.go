@@ -0,0 +1,288 @@
+// Package metrics keeps counters, gauges and histograms of the executor and
+// serves them in the Prometheus text format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	registryLock sync.Mutex
+	registry     []*metric
+	scrapeHooks  []func()
+)
+
+// metric is a family of series of one name, one series per combination of
+// label values
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func newMetric(kind, name, help string, buckets []float64, labels []string) *metric {
+	m := &metric{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*series{},
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registry = append(registry, m)
+	return m
+}
+
+// get returns the series of the label values, creating it on first use.
+// The caller holds the lock.
+func (m *metric) get(labelValues []string) *series {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s has labels %v, got values %v", m.name, m.labels, labelValues))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{
+			labelValues: append([]string{}, labelValues...),
+			counts:      make([]uint64, len(m.buckets)),
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+func (m *metric) value(labelValues []string) float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.get(labelValues).value
+}
+
+// Counter only goes up, such as the number of events received
+type Counter struct {
+	m *metric
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newMetric("counter", name, help, nil, labels)}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.m.lock.Lock()
+	defer c.m.lock.Unlock()
+	c.m.get(labelValues).value += value
+}
+
+// Set copies a count kept elsewhere, typically from a scrape hook
+func (c *Counter) Set(value float64, labelValues ...string) {
+	c.m.lock.Lock()
+	defer c.m.lock.Unlock()
+	c.m.get(labelValues).value = value
+}
+
+// Init creates the series of the label values so that they are reported
+// before anything is recorded
+func (c *Counter) Init(labelValues ...string) {
+	c.Add(0, labelValues...)
+}
+
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.m.value(labelValues)
+}
+
+// Gauge goes up and down, such as the number of stacks being deployed
+type Gauge struct {
+	m *metric
+}
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newMetric("gauge", name, help, nil, labels)}
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	g.m.lock.Lock()
+	defer g.m.lock.Unlock()
+	g.m.get(labelValues).value += value
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.m.lock.Lock()
+	defer g.m.lock.Unlock()
+	g.m.get(labelValues).value = value
+}
+
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.m.value(labelValues)
+}
+
+// Histogram counts observations, such as durations in seconds, in buckets
+type Histogram struct {
+	m *metric
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{newMetric("histogram", name, help, buckets, labels)}
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.m.lock.Lock()
+	defer h.m.lock.Unlock()
+	s := h.m.get(labelValues)
+	for i, bound := range h.m.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Init creates the series of the label values so that they are reported
+// before anything is observed
+func (h *Histogram) Init(labelValues ...string) {
+	h.m.lock.Lock()
+	defer h.m.lock.Unlock()
+	h.m.get(labelValues)
+}
+
+// OnScrape registers a function run before every scrape, to update metrics
+// from state kept elsewhere
+func OnScrape(f func()) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	scrapeHooks = append(scrapeHooks, f)
+}
+
+// Handler serves the metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		buf := &bytes.Buffer{}
+		Write(buf)
+		rw.Header().Set("Content-Type", contentType)
+		rw.Write(buf.Bytes())
+	})
+}
+
+// Write writes every metric in the Prometheus text format
+func Write(w io.Writer) {
+	registryLock.Lock()
+	hooks := append([]func(){}, scrapeHooks...)
+	metrics := append([]*metric{}, registry...)
+	registryLock.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].name < metrics[j].name
+	})
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+func (m *metric) write(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, labelPairs(m.labels, s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+		for i, bound := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, labelPairs(m.labels, s.labelValues, "le", formatValue(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, labelPairs(m.labels, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, labelPairs(m.labels, s.labelValues, "", ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, labelPairs(m.labels, s.labelValues, "", ""), s.count)
+	}
+}
+
+func labelPairs(labels, values []string, extraLabel, extraValue string) string {
+	pairs := []string{}
+	for i, label := range labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, escapeLabel(values[i])))
+	}
+	if extraLabel != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraLabel, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
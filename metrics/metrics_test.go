@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	c := NewCounter("test_requests_total", "Requests\nmade.", "path")
+	c.Inc("/a")
+	c.Add(2, `/"b"`)
+	g := NewGauge("test_running", "Running things.")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+	h := NewHistogram("test_seconds", "Durations.", []float64{1, 0.5}, "op")
+	h.Observe(0.2, "get")
+	h.Observe(0.7, "get")
+	h.Observe(3, "get")
+
+	buf := &bytes.Buffer{}
+	Write(buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# HELP test_requests_total Requests\\nmade.\n# TYPE test_requests_total counter\n")
+	assert.Contains(t, out, "test_requests_total{path=\"/a\"} 1\n")
+	assert.Contains(t, out, "test_requests_total{path=\"/\\\"b\\\"\"} 2\n")
+	assert.Contains(t, out, "# TYPE test_running gauge\ntest_running 1\n")
+	assert.Contains(t, out, "# TYPE test_seconds histogram\n"+
+		"test_seconds_bucket{op=\"get\",le=\"0.5\"} 1\n"+
+		"test_seconds_bucket{op=\"get\",le=\"1\"} 2\n"+
+		"test_seconds_bucket{op=\"get\",le=\"+Inf\"} 3\n"+
+		"test_seconds_sum{op=\"get\"} 3.9\n"+
+		"test_seconds_count{op=\"get\"} 3\n")
+}
+
+func TestOnScrape(t *testing.T) {
+	c := NewCounter("test_scraped_total", "Scraped.")
+	n := 0
+	OnScrape(func() {
+		n++
+		c.Set(float64(n * 10))
+	})
+
+	rw := httptest.NewRecorder()
+	Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, contentType, rw.Header().Get("Content-Type"))
+	assert.Contains(t, rw.Body.String(), "test_scraped_total 10\n")
+
+	rw = httptest.NewRecorder()
+	Handler().ServeHTTP(rw, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, rw.Body.String(), "test_scraped_total 20\n")
+}
+
+func TestResourceType(t *testing.T) {
+	assert.Equal(t, "services", ResourceType("/v3/projects/1a5/services/1s1"))
+	assert.Equal(t, "stacks", ResourceType("/v3/stacks"))
+	assert.Equal(t, "projects", ResourceType("/v3/projects"))
+	assert.Equal(t, "schemas", ResourceType("/v3/projects/1a5/schemas"))
+	assert.Equal(t, "templateversions", ResourceType("/v1-catalog/templateversions/library:x:0"))
+	assert.Equal(t, "root", ResourceType("/v3"))
+	assert.Equal(t, "other", ResourceType("/ping"))
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{RoundTripper: http.DefaultTransport}}
+	resp, err := client.Get(server.URL + "/v3/projects/1a5/certificates/1c1")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+
+	buf := &bytes.Buffer{}
+	Write(buf)
+	assert.Contains(t, buf.String(), prefix+"api_requests_total{type=\"certificates\",method=\"GET\",code=\"404\"} 1\n")
+	assert.Contains(t, buf.String(), prefix+"api_request_duration_seconds_count{type=\"certificates\",method=\"GET\"} 1\n")
+}
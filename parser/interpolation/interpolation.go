@@ -5,7 +5,8 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/Sirupsen/logrus"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"golang.org/x/net/context"
 )
 
 func isNum(c uint8) bool {
@@ -138,11 +139,11 @@ func parseConfig(key string, data *interface{}, mapping func(string) string) err
 }
 
 // Interpolate replaces variables in a map entry
-func Interpolate(key string, data *interface{}, env map[string]string) error {
+func Interpolate(ctx context.Context, key string, data *interface{}, env map[string]string) error {
 	return parseConfig(key, data, func(s string) string {
 		value, ok := env[s]
 		if !ok {
-			logrus.Warnf("The %s variable is not set. Substituting a blank string.", s)
+			logging.FromContext(ctx).Warnf("The %s variable is not set. Substituting a blank string.", s)
 			return ""
 		}
 
@@ -17,6 +17,7 @@ import (
 	"github.com/rancher/rancher-compose-executor/template"
 	"github.com/rancher/rancher-compose-executor/utils"
 	composeYaml "github.com/rancher/rancher-compose-executor/yaml"
+	"golang.org/x/net/context"
 	"gopkg.in/yaml.v2"
 )
 
@@ -60,7 +61,7 @@ func createRawConfig(contents []byte) (*config.RawConfig, error) {
 		return nil, err
 	}
 
-	if rawConfig.Version != "2" {
+	if !isV2(rawConfig.Version) && !isV3(rawConfig.Version) {
 		var baseRawServices config.RawServiceMap
 		if err := yaml.Unmarshal(contents, &baseRawServices); err != nil {
 			return nil, err
@@ -87,6 +88,12 @@ func createRawConfig(contents []byte) (*config.RawConfig, error) {
 		rawConfig.Secrets = make(map[string]interface{})
 	}
 
+	if isV3(rawConfig.Version) {
+		if err := mergeConfigsV3(&rawConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	// Merge other service types into primary service map
 	for name, baseRawLoadBalancer := range rawConfig.LoadBalancers {
 		rawConfig.Services[name] = baseRawLoadBalancer
@@ -123,7 +130,7 @@ func createRawConfig(contents []byte) (*config.RawConfig, error) {
 }
 
 // Merge merges a compose file into an existing set of service configs
-func Merge(existingServices map[string]*config.ServiceConfig, vars map[string]string, resourceLookup lookup.ResourceLookup, templateVersion *catalog.TemplateVersion, cluster *client.Cluster, file string, contents []byte) (*config.Config, error) {
+func Merge(ctx context.Context, existingServices map[string]*config.ServiceConfig, vars map[string]string, resourceLookup lookup.ResourceLookup, templateVersion *catalog.TemplateVersion, cluster *client.Cluster, file string, contents []byte) (*config.Config, error) {
 	var err error
 	contents, err = template.Apply(contents, templateVersion, cluster, vars)
 	if err != nil {
@@ -139,27 +146,34 @@ func Merge(existingServices map[string]*config.ServiceConfig, vars map[string]st
 	baseRawContainers := rawConfig.Containers
 
 	// TODO: just interpolate at the map level earlier
-	if err := interpolateRawServiceMap(&baseRawServices, vars); err != nil {
+	if err := interpolateRawServiceMap(ctx, &baseRawServices, vars); err != nil {
 		return nil, err
 	}
-	if err := interpolateRawServiceMap(&baseRawContainers, vars); err != nil {
+	if err := interpolateRawServiceMap(ctx, &baseRawContainers, vars); err != nil {
 		return nil, err
 	}
 
 	for k, v := range rawConfig.Volumes {
-		if err := interpolation.Interpolate(k, &v, vars); err != nil {
+		if err := interpolation.Interpolate(ctx, k, &v, vars); err != nil {
 			return nil, err
 		}
 		rawConfig.Volumes[k] = v
 	}
 
 	for k, v := range rawConfig.Networks {
-		if err := interpolation.Interpolate(k, &v, vars); err != nil {
+		if err := interpolation.Interpolate(ctx, k, &v, vars); err != nil {
 			return nil, err
 		}
 		rawConfig.Networks[k] = v
 	}
 
+	for k, v := range rawConfig.Certificates {
+		if err := interpolation.Interpolate(ctx, k, &v, vars); err != nil {
+			return nil, err
+		}
+		rawConfig.Certificates[k] = v
+	}
+
 	baseRawServices, err = preProcessServiceMap(baseRawServices)
 	if err != nil {
 		return nil, err
@@ -179,14 +193,20 @@ func Merge(existingServices map[string]*config.ServiceConfig, vars map[string]st
 	}
 
 	var serviceConfigs map[string]*config.ServiceConfig
-	if rawConfig.Version == "2" {
+	if isV2(rawConfig.Version) {
 		var err error
-		serviceConfigs, err = mergeServicesV2(vars, resourceLookup, file, baseRawServices)
+		serviceConfigs, err = mergeServicesV2(ctx, vars, resourceLookup, file, baseRawServices)
+		if err != nil {
+			return nil, err
+		}
+	} else if isV3(rawConfig.Version) {
+		var err error
+		serviceConfigs, err = mergeServicesV3(ctx, vars, resourceLookup, file, baseRawServices)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		serviceConfigsV1, err := mergeServicesV1(vars, resourceLookup, file, baseRawServices)
+		serviceConfigsV1, err := mergeServicesV1(ctx, vars, resourceLookup, file, baseRawServices)
 		if err != nil {
 			return nil, err
 		}
@@ -215,9 +235,15 @@ func Merge(existingServices map[string]*config.ServiceConfig, vars map[string]st
 	}
 
 	var containerConfigs map[string]*config.ServiceConfig
-	if rawConfig.Version == "2" {
+	if isV2(rawConfig.Version) {
+		var err error
+		containerConfigs, err = mergeServicesV2(ctx, vars, resourceLookup, file, baseRawContainers)
+		if err != nil {
+			return nil, err
+		}
+	} else if isV3(rawConfig.Version) {
 		var err error
-		containerConfigs, err = mergeServicesV2(vars, resourceLookup, file, baseRawContainers)
+		containerConfigs, err = mergeServicesV3(ctx, vars, resourceLookup, file, baseRawContainers)
 		if err != nil {
 			return nil, err
 		}
@@ -230,6 +256,7 @@ func Merge(existingServices map[string]*config.ServiceConfig, vars map[string]st
 	var volumes map[string]*config.VolumeConfig
 	var networks map[string]*config.NetworkConfig
 	var secrets map[string]*config.SecretConfig
+	var certificates map[string]*config.CertificateConfig
 	var hosts map[string]*config.HostConfig
 	if err := utils.Convert(rawConfig.Dependencies, &dependencies); err != nil {
 		return nil, err
@@ -251,23 +278,64 @@ func Merge(existingServices map[string]*config.ServiceConfig, vars map[string]st
 	if err := utils.Convert(rawConfig.Secrets, &secrets); err != nil {
 		return nil, err
 	}
+	if err := utils.Convert(rawConfig.Certificates, &certificates); err != nil {
+		return nil, err
+	}
+	for name, certificate := range certificates {
+		if certificate == nil || certificate.Cert == "" || certificate.Key == "" {
+			return nil, fmt.Errorf("Certificate %s must have a cert and a key", name)
+		}
+		certificate.Cert = resolveCertificateFile(certificate.Cert, file)
+		certificate.Key = resolveCertificateFile(certificate.Key, file)
+		certificate.Chain = resolveCertificateFile(certificate.Chain, file)
+	}
+	for _, secret := range secrets {
+		if secret != nil && secret.File != "" {
+			secret.File = lookup.Resolve(secret.File, file)
+		}
+	}
+
+	switch rawConfig.FinishUpgrade {
+	case "", config.FinishUpgradeAuto, config.FinishUpgradeManual:
+	default:
+		return nil, fmt.Errorf("Invalid finish_upgrade %s, must be %s or %s", rawConfig.FinishUpgrade, config.FinishUpgradeAuto, config.FinishUpgradeManual)
+	}
 
 	return &config.Config{
+		FinishUpgrade:       rawConfig.FinishUpgrade,
 		Services:            serviceConfigs,
 		Containers:          containerConfigs,
 		Dependencies:        dependencies,
 		Volumes:             volumes,
 		Networks:            networks,
 		Secrets:             secrets,
+		Certificates:        certificates,
 		Hosts:               hosts,
 		KubernetesResources: rawConfig.KubernetesResources,
 	}, nil
 }
 
-func interpolateRawServiceMap(baseRawServices *config.RawServiceMap, vars map[string]string) error {
+// resolveCertificateFile makes a certificate file relative to the directory
+// of the project instead of the file it's defined in. PEM content is kept.
+func resolveCertificateFile(value, inFile string) string {
+	if value == "" || strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return value
+	}
+	return lookup.Resolve(value, inFile)
+}
+
+func isV2(version string) bool {
+	return version == "2" || strings.HasPrefix(version, "2.")
+}
+
+func isV3(version string) bool {
+	return version == "3" || strings.HasPrefix(version, "3.")
+}
+
+func interpolateRawServiceMap(ctx context.Context, baseRawServices *config.RawServiceMap, vars map[string]string) error {
 	for k, v := range *baseRawServices {
 		for k2, v2 := range v {
-			if err := interpolation.Interpolate(k2, &v2, vars); err != nil {
+			if err := interpolation.Interpolate(ctx, k2, &v2, vars); err != nil {
 				return err
 			}
 			(*baseRawServices)[k][k2] = v2
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMergeFinishUpgrade(t *testing.T) {
+	c, err := Merge(context.Background(), nil, nil, nil, nil, &client.Cluster{}, "docker-compose.yml", []byte(`
+version: "2"
+finish_upgrade: manual
+services:
+  web:
+    image: nginx
+  db:
+    image: mysql
+    finish_upgrade: auto
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, c.ManualFinishUpgrade("web"))
+	assert.False(t, c.ManualFinishUpgrade("db"))
+
+	_, err = Merge(context.Background(), nil, nil, nil, nil, &client.Cluster{}, "docker-compose.yml", []byte(`
+version: "2"
+finish_upgrade: later
+services:
+  web:
+    image: nginx
+`))
+	assert.Error(t, err)
+}
+
+func TestMergeCertificates(t *testing.T) {
+	c, err := Merge(context.Background(), nil, map[string]string{
+		"CERT": "-----BEGIN CERTIFICATE-----\nabc\n-----END CERTIFICATE-----",
+	}, nil, nil, &client.Cluster{}, "docker-compose.yml", []byte(`
+version: "2"
+certificates:
+  web:
+    cert: ${CERT}
+    key: ./web.key
+services:
+  lb:
+    image: rancher/lb-service-haproxy
+    lb_config:
+      default_cert: web
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.NotNil(t, c.Certificates["web"]) {
+		assert.Equal(t, "-----BEGIN CERTIFICATE-----\nabc\n-----END CERTIFICATE-----", c.Certificates["web"].Cert)
+		assert.Equal(t, "web.key", c.Certificates["web"].Key)
+	}
+
+	_, err = Merge(context.Background(), nil, nil, nil, nil, &client.Cluster{}, "docker-compose.yml", []byte(`
+version: "2"
+certificates:
+  web:
+    cert: ./web.crt
+`))
+	assert.Error(t, err)
+}
+
+func TestMergeResolvesSecretFiles(t *testing.T) {
+	c, err := Merge(context.Background(), nil, nil, nil, nil, &client.Cluster{}, "sub/docker-compose.yml", []byte(`
+version: "2"
+secrets:
+  password:
+    file: ./password.txt
+  shared:
+    file: ../shared.txt
+  external:
+    external: "true"
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "sub/password.txt", c.Secrets["password"].File)
+	assert.Equal(t, "shared.txt", c.Secrets["shared"].File)
+	assert.Equal(t, "", c.Secrets["external"].File)
+}
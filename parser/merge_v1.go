@@ -4,23 +4,24 @@ import (
 	"fmt"
 	"path"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/lookup"
 	"github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
 )
 
 // mergeServicesV1 merges a v1 compose file into an existing set of service configs
-func mergeServicesV1(vars map[string]string, resourceLookup lookup.ResourceLookup, file string, datas config.RawServiceMap) (map[string]*config.ServiceConfigV1, error) {
+func mergeServicesV1(ctx context.Context, vars map[string]string, resourceLookup lookup.ResourceLookup, file string, datas config.RawServiceMap) (map[string]*config.ServiceConfigV1, error) {
 	if err := validate(datas); err != nil {
 		return nil, err
 	}
 
 	for name, data := range datas {
 		var err error
-		datas[name], err = parseV1(resourceLookup, vars, file, data, datas)
+		datas[name], err = parseV1(ctx, resourceLookup, vars, file, data, datas)
 		if err != nil {
-			logrus.Errorf("Failed to parse service %s: %v", name, err)
+			logging.FromContext(ctx).Errorf("Failed to parse service %s: %v", name, err)
 			return nil, err
 		}
 	}
@@ -33,7 +34,7 @@ func mergeServicesV1(vars map[string]string, resourceLookup lookup.ResourceLooku
 	return serviceConfigs, nil
 }
 
-func parseV1(resourceLookup lookup.ResourceLookup, vars map[string]string, inFile string, serviceData config.RawService, datas config.RawServiceMap) (config.RawService, error) {
+func parseV1(ctx context.Context, resourceLookup lookup.ResourceLookup, vars map[string]string, inFile string, serviceData config.RawService, datas config.RawServiceMap) (config.RawService, error) {
 	serviceData, err := readEnvFile(resourceLookup, inFile, serviceData)
 	if err != nil {
 		return nil, err
@@ -66,14 +67,14 @@ func parseV1(resourceLookup lookup.ResourceLookup, vars map[string]string, inFil
 
 	if file == "" {
 		if serviceData, ok := datas[service]; ok {
-			baseService, err = parseV1(resourceLookup, vars, inFile, serviceData, datas)
+			baseService, err = parseV1(ctx, resourceLookup, vars, inFile, serviceData, datas)
 		} else {
 			return nil, fmt.Errorf("Failed to find service %s to extend", service)
 		}
 	} else {
 		bytes, resolved, err := resourceLookup.Lookup(file, inFile)
 		if err != nil {
-			logrus.Errorf("Failed to lookup file %s: %v", file, err)
+			logging.FromContext(ctx).Errorf("Failed to lookup file %s: %v", file, err)
 			return nil, err
 		}
 
@@ -83,7 +84,7 @@ func parseV1(resourceLookup lookup.ResourceLookup, vars map[string]string, inFil
 		}
 		baseRawServices := rawConfig.Services
 
-		if err = interpolateRawServiceMap(&baseRawServices, vars); err != nil {
+		if err = interpolateRawServiceMap(ctx, &baseRawServices, vars); err != nil {
 			return nil, err
 		}
 
@@ -101,7 +102,7 @@ func parseV1(resourceLookup lookup.ResourceLookup, vars map[string]string, inFil
 			return nil, fmt.Errorf("Failed to find service %s in file %s", service, file)
 		}
 
-		baseService, err = parseV1(resourceLookup, vars, resolved, baseService, baseRawServices)
+		baseService, err = parseV1(ctx, resourceLookup, vars, resolved, baseService, baseRawServices)
 		if err != nil {
 			return nil, err
 		}
@@ -109,7 +110,7 @@ func parseV1(resourceLookup lookup.ResourceLookup, vars map[string]string, inFil
 
 	baseService = clone(baseService)
 
-	logrus.Debugf("Merging %#v, %#v", baseService, serviceData)
+	logging.FromContext(ctx).Debugf("Merging %#v, %#v", baseService, serviceData)
 
 	for _, k := range noMerge {
 		if _, ok := baseService[k]; ok {
@@ -123,7 +124,7 @@ func parseV1(resourceLookup lookup.ResourceLookup, vars map[string]string, inFil
 
 	baseService = mergeConfig(baseService, serviceData)
 
-	logrus.Debugf("Merged result %#v", baseService)
+	logging.FromContext(ctx).Debugf("Merged result %#v", baseService)
 
 	return baseService, nil
 }
@@ -0,0 +1,283 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/lookup"
+	"github.com/rancher/rancher-compose-executor/utils"
+	composeYaml "github.com/rancher/rancher-compose-executor/yaml"
+	"golang.org/x/net/context"
+)
+
+const (
+	hostLabelAffinity     = "io.rancher.scheduler.affinity:host_label"
+	hostLabelAntiAffinity = "io.rancher.scheduler.affinity:host_label_ne"
+	globalLabel           = "io.rancher.scheduler.global"
+)
+
+// mergeServicesV3 merges a v3 compose file into an existing set of service configs
+func mergeServicesV3(ctx context.Context, vars map[string]string, resourceLookup lookup.ResourceLookup, file string, datas config.RawServiceMap) (map[string]*config.ServiceConfig, error) {
+	if err := validateV3(datas); err != nil {
+		return nil, err
+	}
+
+	for name, data := range datas {
+		var err error
+		data, err = convertServiceV3(ctx, name, data)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Failed to convert service %s: %v", name, err)
+			return nil, err
+		}
+		datas[name], err = parseV2(ctx, resourceLookup, vars, file, data, datas)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Failed to parse service %s: %v", name, err)
+			return nil, err
+		}
+	}
+
+	serviceConfigs := make(map[string]*config.ServiceConfig)
+	if err := utils.Convert(datas, &serviceConfigs); err != nil {
+		return nil, err
+	}
+
+	return serviceConfigs, nil
+}
+
+// mergeConfigsV3 adds the top level configs of a v3 file to its secrets, as
+// Rancher delivers both as files mounted under /run/secrets
+func mergeConfigsV3(rawConfig *config.RawConfig) error {
+	for name, value := range rawConfig.Configs {
+		if _, ok := rawConfig.Secrets[name]; ok {
+			return fmt.Errorf("Config %s conflicts with a secret of the same name", name)
+		}
+		rawConfig.Secrets[name] = value
+	}
+	return nil
+}
+
+// convertServiceV3 rewrites the v3 only keys of a service into their v2 and
+// rancher-compose equivalents
+func convertServiceV3(ctx context.Context, name string, serviceData config.RawService) (config.RawService, error) {
+	if deploy, ok := serviceData["deploy"].(map[interface{}]interface{}); ok {
+		if err := convertDeployV3(ctx, serviceData, deploy); err != nil {
+			return nil, err
+		}
+	}
+	delete(serviceData, "deploy")
+
+	secrets, err := convertFileReferencesV3(ctx, name, serviceData["secrets"], false)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := convertFileReferencesV3(ctx, name, serviceData["configs"], true)
+	if err != nil {
+		return nil, err
+	}
+	delete(serviceData, "configs")
+	if len(secrets)+len(configs) > 0 {
+		serviceData["secrets"] = append(secrets, configs...)
+	}
+
+	return serviceData, nil
+}
+
+func convertDeployV3(ctx context.Context, serviceData config.RawService, deploy map[interface{}]interface{}) error {
+	labels := map[string]string{}
+
+	if replicas, ok := deploy["replicas"]; ok {
+		if _, ok := serviceData["scale"]; !ok {
+			scale, err := strconv.Atoi(fmt.Sprint(replicas))
+			if err != nil {
+				return fmt.Errorf("Invalid deploy replicas %v: %v", replicas, err)
+			}
+			serviceData["scale"] = scale
+		}
+	}
+
+	if asString(deploy["mode"]) == "global" {
+		labels[globalLabel] = "true"
+	}
+
+	if deployLabels, ok := deploy["labels"]; ok {
+		var parsed composeYaml.SliceorMap
+		if err := utils.Convert(deployLabels, &parsed); err != nil {
+			return err
+		}
+		for k, v := range parsed {
+			labels[k] = v
+		}
+	}
+
+	if updateConfig, ok := deploy["update_config"].(map[interface{}]interface{}); ok {
+		if err := convertUpdateConfigV3(serviceData, updateConfig); err != nil {
+			return err
+		}
+	}
+
+	if placement, ok := deploy["placement"].(map[interface{}]interface{}); ok {
+		constraints, _ := placement["constraints"].([]interface{})
+		for _, constraint := range constraints {
+			key, value, ok := placementConstraintToLabel(fmt.Sprint(constraint))
+			if !ok {
+				logging.FromContext(ctx).Warnf("Ignoring unsupported placement constraint %q", constraint)
+				continue
+			}
+			if existing, ok := labels[key]; ok {
+				value = existing + "," + value
+			}
+			labels[key] = value
+		}
+	}
+
+	return addLabels(serviceData, labels)
+}
+
+func convertUpdateConfigV3(serviceData config.RawService, updateConfig map[interface{}]interface{}) error {
+	upgradeStrategy, ok := serviceData["upgrade_strategy"].(map[interface{}]interface{})
+	if !ok {
+		upgradeStrategy = map[interface{}]interface{}{}
+	}
+
+	if parallelism, ok := updateConfig["parallelism"]; ok {
+		if _, ok := upgradeStrategy["batch_size"]; !ok {
+			batchSize, err := strconv.Atoi(fmt.Sprint(parallelism))
+			if err != nil {
+				return fmt.Errorf("Invalid update_config parallelism %v: %v", parallelism, err)
+			}
+			upgradeStrategy["batch_size"] = batchSize
+		}
+	}
+
+	if delay, ok := updateConfig["delay"]; ok {
+		if _, ok := upgradeStrategy["interval_millis"]; !ok {
+			interval, err := durationToMillis(delay)
+			if err != nil {
+				return err
+			}
+			upgradeStrategy["interval_millis"] = interval
+		}
+	}
+
+	if asString(updateConfig["order"]) == "start-first" {
+		if _, ok := upgradeStrategy["start_first"]; !ok {
+			upgradeStrategy["start_first"] = true
+		}
+	}
+
+	if len(upgradeStrategy) > 0 {
+		serviceData["upgrade_strategy"] = upgradeStrategy
+	}
+	return nil
+}
+
+// placementConstraintToLabel translates a swarm placement constraint such as
+// "node.labels.zone == east" into a Rancher host label scheduling rule
+func placementConstraintToLabel(constraint string) (string, string, bool) {
+	key := hostLabelAffinity
+	parts := strings.SplitN(constraint, "==", 2)
+	if len(parts) != 2 {
+		key = hostLabelAntiAffinity
+		parts = strings.SplitN(constraint, "!=", 2)
+	}
+	if len(parts) != 2 {
+		return "", "", false
+	}
+
+	field := strings.TrimSpace(parts[0])
+	value := strings.TrimSpace(parts[1])
+
+	var label string
+	for _, prefix := range []string{"node.labels.", "engine.labels."} {
+		if strings.HasPrefix(field, prefix) {
+			label = strings.TrimPrefix(field, prefix)
+			break
+		}
+	}
+	if label == "" || value == "" {
+		return "", "", false
+	}
+
+	return key, fmt.Sprintf("%s=%s", label, value), true
+}
+
+// convertFileReferencesV3 normalizes the short and long syntax of service
+// secrets and configs of a service into the secret reference format
+func convertFileReferencesV3(ctx context.Context, name string, value interface{}, isConfig bool) ([]interface{}, error) {
+	references, ok := value.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var result []interface{}
+	for _, reference := range references {
+		switch typed := reference.(type) {
+		case string:
+			result = append(result, typed)
+		case map[interface{}]interface{}:
+			converted := map[interface{}]interface{}{}
+			for k, v := range typed {
+				converted[k] = v
+			}
+			if mode, ok := converted["mode"].(int); ok {
+				converted["mode"] = fmt.Sprintf("%04o", mode)
+			}
+			if target := asString(converted["target"]); isConfig && target != "" {
+				// Configs are mounted as files under /run/secrets
+				converted["target"] = path.Base(target)
+				if target != path.Base(target) && path.Dir(target) != "/run/secrets" {
+					logging.FromContext(ctx).Warnf("Service %s mounts config %v at %s, it is mounted at /run/secrets/%s instead",
+						name, converted["source"], target, path.Base(target))
+				}
+			}
+			if _, ok := converted["target"]; !ok {
+				converted["target"] = converted["source"]
+			}
+			result = append(result, converted)
+		default:
+			return nil, fmt.Errorf("Invalid secret reference %v", reference)
+		}
+	}
+
+	return result, nil
+}
+
+func addLabels(serviceData config.RawService, labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	merged := map[interface{}]interface{}{}
+	if existing, ok := serviceData["labels"]; ok && existing != nil {
+		var parsed composeYaml.SliceorMap
+		if err := utils.Convert(existing, &parsed); err != nil {
+			return err
+		}
+		for k, v := range parsed {
+			merged[k] = v
+		}
+	}
+
+	for k, v := range labels {
+		if existing, ok := merged[k]; ok && (k == hostLabelAffinity || k == hostLabelAntiAffinity) {
+			v = fmt.Sprintf("%s,%s", existing, v)
+		}
+		merged[k] = v
+	}
+
+	serviceData["labels"] = merged
+	return nil
+}
+
+func durationToMillis(value interface{}) (int64, error) {
+	duration, err := time.ParseDuration(fmt.Sprint(value))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration %v: %v", value, err)
+	}
+	return int64(duration / time.Millisecond), nil
+}
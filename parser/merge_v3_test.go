@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestMergeV3(t *testing.T) {
+	c, err := Merge(context.Background(), nil, nil, nil, nil, &client.Cluster{}, "docker-compose.yml", []byte(`
+version: "3.7"
+services:
+  web:
+    image: nginx
+    labels:
+      foo: bar
+    deploy:
+      mode: replicated
+      replicas: 3
+      update_config:
+        parallelism: 2
+        delay: 10s
+        order: start-first
+      placement:
+        constraints:
+          - node.labels.zone == east
+          - node.labels.disk != hdd
+          - node.role == manager
+    healthcheck:
+      test: ["CMD", "curl", "-f", "http://localhost:8080/ping"]
+      interval: 5s
+      timeout: 2s
+      retries: 3
+      start_period: 1m
+    secrets:
+      - source: password
+        target: db_password
+        mode: 0440
+    configs:
+      - source: nginx
+        target: /etc/nginx/nginx.conf
+secrets:
+  password:
+    file: ./password.txt
+configs:
+  nginx:
+    file: ./nginx.conf
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	web := c.Services["web"]
+	assert.Equal(t, "nginx", web.Image)
+	assert.EqualValues(t, 3, web.Scale)
+	assert.Equal(t, "bar", web.Labels["foo"])
+	assert.Equal(t, "zone=east", web.Labels[hostLabelAffinity])
+	assert.Equal(t, "disk=hdd", web.Labels[hostLabelAntiAffinity])
+
+	assert.EqualValues(t, 2, web.UpgradeStrategy.BatchSize)
+	assert.EqualValues(t, 10000, web.UpgradeStrategy.IntervalMillis)
+	assert.True(t, web.UpgradeStrategy.StartFirst)
+
+	if assert.NotNil(t, web.Healthcheck) {
+		assert.Equal(t, []string{"CMD", "curl", "-f", "http://localhost:8080/ping"}, []string(web.Healthcheck.Test))
+		assert.Equal(t, "5s", web.Healthcheck.Interval)
+		assert.Equal(t, "1m", web.Healthcheck.StartPeriod)
+		assert.EqualValues(t, 3, web.Healthcheck.Retries)
+	}
+
+	if assert.Len(t, web.Secrets, 2) {
+		assert.Equal(t, "password", web.Secrets[0].Source)
+		assert.Equal(t, "db_password", web.Secrets[0].Target)
+		assert.Equal(t, "0440", web.Secrets[0].Mode)
+		assert.Equal(t, "nginx", web.Secrets[1].Source)
+		assert.Equal(t, "nginx.conf", web.Secrets[1].Target)
+	}
+
+	assert.Equal(t, "password.txt", c.Secrets["password"].File)
+	assert.Equal(t, "nginx.conf", c.Secrets["nginx"].File)
+}
+
+func TestMergeV3Invalid(t *testing.T) {
+	_, err := Merge(context.Background(), nil, nil, nil, nil, &client.Cluster{}, "docker-compose.yml", []byte(`
+version: "3"
+services:
+  web:
+    image: nginx
+    deploy:
+      replicas: 1
+      unknown: true
+`))
+	assert.Error(t, err)
+}
+
+func TestMergeV3ConfigTarget(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &out
+	ctx := logging.WithField(logging.WithLogger(context.Background(), logrus.NewEntry(logger)), logging.StackName, "test")
+
+	c, err := Merge(ctx, nil, nil, nil, nil, &client.Cluster{}, "docker-compose.yml", []byte(`
+version: "3.3"
+services:
+  web:
+    image: ${IMAGE}
+    configs:
+      - source: nginx
+        target: /etc/nginx/nginx.conf
+      - source: site
+        target: /run/secrets/site.conf
+      - source: mime
+        target: mime.types
+configs:
+  nginx:
+    file: ./nginx.conf
+  site:
+    file: ./site.conf
+  mime:
+    file: ./mime.types
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, c.Services["web"].Secrets, 3) {
+		assert.Equal(t, "nginx.conf", c.Services["web"].Secrets[0].Target)
+		assert.Equal(t, "site.conf", c.Services["web"].Secrets[1].Target)
+		assert.Equal(t, "mime.types", c.Services["web"].Secrets[2].Target)
+	}
+	// Only the config moved away from its target is warned about, through
+	// the logger of the stack
+	assert.Contains(t, out.String(), "Service web mounts config nginx at /etc/nginx/nginx.conf, it is mounted at /run/secrets/nginx.conf instead")
+	assert.Contains(t, out.String(), "The IMAGE variable is not set")
+	assert.Equal(t, 2, strings.Count(out.String(), "level=warning"))
+	assert.Equal(t, 2, strings.Count(out.String(), "stackName=test"))
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/docker/go-connections/nat"
 	"github.com/xeipuuv/gojsonschema"
@@ -13,13 +14,17 @@ var (
 	constraintSchemaLoaderV1 gojsonschema.JSONLoader
 	schemaLoaderV2           gojsonschema.JSONLoader
 	constraintSchemaLoaderV2 gojsonschema.JSONLoader
+	schemaLoaderV3           gojsonschema.JSONLoader
+	constraintSchemaLoaderV3 gojsonschema.JSONLoader
 	schemaV1                 map[string]interface{}
 	schemaV2                 map[string]interface{}
+	schemaV3                 map[string]interface{}
 )
 
 type (
 	environmentFormatChecker struct{}
 	portsFormatChecker       struct{}
+	durationFormatChecker    struct{}
 )
 
 func (checker environmentFormatChecker) IsFormat(input string) bool {
@@ -34,6 +39,11 @@ func (checker portsFormatChecker) IsFormat(input string) bool {
 	return err == nil
 }
 
+func (checker durationFormatChecker) IsFormat(input string) bool {
+	_, err := time.ParseDuration(input)
+	return err == nil
+}
+
 func setupSchemaLoaders(schemaData string, schema *map[string]interface{}, schemaLoader, constraintSchemaLoader *gojsonschema.JSONLoader) error {
 	if *schema != nil {
 		return nil
@@ -50,6 +60,7 @@ func setupSchemaLoaders(schemaData string, schema *map[string]interface{}, schem
 	gojsonschema.FormatCheckers.Add("environment", environmentFormatChecker{})
 	gojsonschema.FormatCheckers.Add("ports", portsFormatChecker{})
 	gojsonschema.FormatCheckers.Add("expose", portsFormatChecker{})
+	gojsonschema.FormatCheckers.Add("duration", durationFormatChecker{})
 	*schemaLoader = gojsonschema.NewGoLoader(schemaRaw)
 
 	definitions := (*schema)["definitions"].(map[string]interface{})
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -223,6 +224,23 @@ func validateV2(serviceMap config.RawServiceMap) error {
 	return generateErrorMessages(serviceMap, schemaV2, result)
 }
 
+func validateV3(serviceMap config.RawServiceMap) error {
+	if err := setupSchemaLoaders(servicesSchemaDataV3, &schemaV3, &schemaLoaderV3, &constraintSchemaLoaderV3); err != nil {
+		return err
+	}
+
+	serviceMap = convertServiceMapKeysToStrings(serviceMap)
+
+	dataLoader := gojsonschema.NewGoLoader(serviceMap)
+
+	result, err := gojsonschema.Validate(schemaLoaderV3, dataLoader)
+	if err != nil {
+		return err
+	}
+
+	return generateErrorMessages(serviceMap, schemaV3, result)
+}
+
 func generateErrorMessages(serviceMap config.RawServiceMap, schema map[string]interface{}, result *gojsonschema.Result) error {
 	var validationErrors []string
 
@@ -276,7 +294,7 @@ func generateErrorMessages(serviceMap config.RawServiceMap, schema map[string]in
 			}
 		}
 
-		return fmt.Errorf(strings.Join(validationErrors, "\n"))
+		return errors.New(strings.Join(validationErrors, "\n"))
 	}
 
 	return nil
@@ -315,7 +333,7 @@ func validateServiceConstraints(service config.RawService, serviceName string) e
 			}
 		}
 
-		return fmt.Errorf(strings.Join(validationErrors, "\n"))
+		return errors.New(strings.Join(validationErrors, "\n"))
 	}
 
 	return nil
@@ -1,10 +1,13 @@
 package project
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/rancher/rancher-compose-executor/kubectl"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 	"golang.org/x/net/context"
 )
 
@@ -16,7 +19,39 @@ func (p *Project) Up(ctx context.Context, options options.Options) error {
 	return p.create(ctx, options, true)
 }
 
+// Rollback rolls back the last upgrade of every service and container
+func (p *Project) Rollback(ctx context.Context, options options.Options) error {
+	return p.Action(ctx, RollbackAction, options)
+}
+
+// FinishUpgrade finishes the upgrades that are waiting for confirmation
+func (p *Project) FinishUpgrade(ctx context.Context, options options.Options) error {
+	return p.Action(ctx, FinishUpgradeAction, options)
+}
+
+func (p *Project) Activate(ctx context.Context, options options.Options) error {
+	return p.Action(ctx, ActivateAction, options)
+}
+
+func (p *Project) Deactivate(ctx context.Context, options options.Options) error {
+	return p.Action(ctx, DeactivateAction, options)
+}
+
+func (p *Project) Restart(ctx context.Context, options options.Options) error {
+	return p.Action(ctx, RestartAction, options)
+}
+
+// Remove removes the services and containers of the stack
+func (p *Project) Remove(ctx context.Context, options options.Options) error {
+	return p.Action(ctx, RemoveAction, options)
+}
+
 func (p *Project) Delete(ctx context.Context) error {
+	if len(p.Config.KubernetesResources) == 0 {
+		return nil
+	}
+
+	ctx = logging.WithField(ctx, logging.Phase, "delete")
 	endpoint, err := kubectl.GetClusterEndpoint(p.Client, p.Cluster.Id)
 	if err != nil {
 		return err
@@ -32,9 +67,11 @@ func (p *Project) Delete(ctx context.Context) error {
 	defer os.Remove(kubeconfigLocation)
 
 	for name, resource := range p.Config.KubernetesResources {
-		if err := kubectl.Delete(kubeconfigLocation, name, namespace, resource); err != nil {
+		p.Progress.Update("kubernetes resource", name, fmt.Sprintf("Deleting Kubernetes resource %s", name))
+		if err := kubectl.Delete(ctx, kubeconfigLocation, name, namespace, resource); err != nil {
 			return err
 		}
+		p.Progress.Done("kubernetes resource", name, progress.Removed)
 	}
 
 	return nil
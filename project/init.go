@@ -5,15 +5,16 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/catalog"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/lookup"
 	"github.com/rancher/rancher-compose-executor/lookup/server"
 	"github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
 )
 
-func (p *Project) LoadFromTemplateVersion(templateVersion catalog.TemplateVersion, answers map[string]string) error {
+func (p *Project) LoadFromTemplateVersion(ctx context.Context, templateVersion catalog.TemplateVersion, answers map[string]string) error {
 	p.TemplateVersion = &templateVersion
 
 	defaultedAnswers := map[string]string{}
@@ -27,37 +28,31 @@ func (p *Project) LoadFromTemplateVersion(templateVersion catalog.TemplateVersio
 		}
 	}
 
-	return p.Load(templateVersion.Files, defaultedAnswers)
+	return p.Load(ctx, templateVersion.Files, defaultedAnswers)
 }
 
-func (p *Project) Load(templates map[string]string, answers map[string]string) error {
-	var err error
-
-	// Filter and remove invalid templates
-	// Catalog service will treat files such as README.md and template-version.yml as templates
-	templates = filterTemplates(templates)
-
-	p.Templates = utils.ToMapByte(templates)
-	p.Answers = answers
-
-	if p.ResourceLookup == nil {
-		p.ResourceLookup = &lookup.MemoryResourceLookup{
-			Content: p.Templates,
-		}
+// Load binds the project to its stack and parses the templates
+func (p *Project) Load(ctx context.Context, templates map[string]string, answers map[string]string) error {
+	ctx = logging.WithField(ctx, logging.Phase, "load")
+	if err := p.Bind(ctx); err != nil {
+		return err
 	}
+	return p.Parse(ctx, templates, answers)
+}
 
+// Bind finds the stack of the project, creating it if it doesn't exist, and
+// looks up server side resources through the API from then on
+func (p *Project) Bind(ctx context.Context) error {
 	if p.Name == "" {
 		return errors.New("Name is required")
 	}
 
 	if stackSchema, ok := p.Client.GetTypes()["stack"]; !ok || !utils.Contains(stackSchema.CollectionMethods, "POST") {
-		return fmt.Errorf("Can not create a stack, check API key [%s] for [%s]",
-			p.Client.GetOpts().AccessKey,
-			p.Client.GetOpts().Url)
+		return fmt.Errorf("Can not create a stack, check the API key for [%s]", p.Client.GetOpts().Url)
 	}
 
 	if p.Stack == nil {
-		stack, err := loadStack(p.Name, p.Client)
+		stack, err := loadStack(ctx, p.Name, p.Client)
 		if err != nil {
 			return err
 		}
@@ -66,13 +61,32 @@ func (p *Project) Load(templates map[string]string, answers map[string]string) e
 	}
 
 	if p.ServerResourceLookup == nil {
-		p.ServerResourceLookup = server.NewLookup(p.Stack.Id, p.Client)
+		p.ServerResourceLookup = server.NewLookup(ctx, p.Stack.Id, p.Client)
+	}
+
+	return nil
+}
+
+// Parse renders, interpolates, parses and validates the templates without
+// talking to the server
+func (p *Project) Parse(ctx context.Context, templates map[string]string, answers map[string]string) error {
+	// Filter and remove invalid templates
+	// Catalog service will treat files such as README.md and template-version.yml as templates
+	templates = filterTemplates(templates)
+
+	p.Templates = utils.ToMapByte(templates)
+	p.Answers = answers
+
+	if p.ResourceLookup == nil {
+		p.ResourceLookup = &lookup.MemoryResourceLookup{
+			Content: p.Templates,
+		}
 	}
 
 	defer p.Config.Complete()
 
 	for file, contents := range p.Templates {
-		if err = p.load(file, contents); err != nil {
+		if err := p.load(ctx, file, contents); err != nil {
 			return err
 		}
 	}
@@ -101,8 +115,9 @@ func filterTemplates(templates map[string]string) map[string]string {
 	return filtereredTemplates
 }
 
-func loadStack(projectName string, c *client.RancherClient) (*client.Stack, error) {
-	logrus.Debugf("Looking for stack %s", projectName)
+func loadStack(ctx context.Context, projectName string, c *client.RancherClient) (*client.Stack, error) {
+	logger := logging.FromContext(ctx).WithField(logging.StackName, projectName)
+	logger.Debugf("Looking for stack %s", projectName)
 	// First try by name
 	stacks, err := c.Stack.List(&client.ListOpts{
 		Filters: map[string]interface{}{
@@ -116,7 +131,7 @@ func loadStack(projectName string, c *client.RancherClient) (*client.Stack, erro
 
 	for _, stack := range stacks.Data {
 		if strings.EqualFold(projectName, stack.Name) {
-			logrus.Debugf("Found stack: %s(%s)", stack.Name, stack.Id)
+			logger.Debugf("Found stack: %s(%s)", stack.Name, stack.Id)
 			return &stack, nil
 		}
 	}
@@ -133,12 +148,12 @@ func loadStack(projectName string, c *client.RancherClient) (*client.Stack, erro
 
 	for _, stack := range stacks.Data {
 		if strings.EqualFold(projectName, stack.Name) {
-			logrus.Debugf("Found stack: %s(%s)", stack.Name, stack.Id)
+			logger.Debugf("Found stack: %s(%s)", stack.Name, stack.Id)
 			return &stack, nil
 		}
 	}
 
-	logrus.Infof("Creating stack %s", projectName)
+	logger.Infof("Creating stack %s", projectName)
 	stack, err := c.Stack.Create(&client.Stack{
 		Name: projectName,
 	})
@@ -0,0 +1,42 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestParseOffline(t *testing.T) {
+	p := NewOfflineProject("test", &client.Cluster{
+		Orchestration: "cattle",
+	})
+
+	err := p.Parse(context.Background(), map[string]string{
+		"compose.yml": `
+version: "2"
+services:
+  web:
+    image: nginx:${VERSION}
+{{- if eq .Cluster.Orchestration "cattle" }}
+    labels:
+      orchestration: cattle
+{{- end }}
+`,
+		"README.md": "ignored",
+	}, map[string]string{
+		"VERSION": "1.13",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "nginx:1.13", p.Config.Services["web"].Image)
+	assert.Equal(t, "cattle", p.Config.Services["web"].Labels["orchestration"])
+	assert.NotNil(t, p.Config.SidekickInfo)
+
+	service, err := p.ServerResourceLookup.Service("db")
+	assert.NoError(t, err)
+	assert.Equal(t, "${service:db}", service.Id)
+}
@@ -21,6 +21,11 @@ type Options struct {
 
 	Rollback bool
 	Pull     bool
+	// Prune removes the services and containers of the stack that are
+	// not in the template
+	Prune bool
+	// FinishUpgrade finishes upgrades left for manual confirmation
+	FinishUpgrade bool
 }
 
 // ImageType defines the type of image (local, all)
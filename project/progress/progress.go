@@ -0,0 +1,217 @@
+package progress
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Result is the final state of a resource once the executor is done with it
+type Result string
+
+const (
+	Created   Result = "created"
+	Upgraded  Result = "upgraded"
+	Unchanged Result = "unchanged"
+	Applied   Result = "applied"
+	Removed   Result = "removed"
+	// Results of the stack wide actions
+	RolledBack      Result = "rolled back"
+	FinishedUpgrade Result = "finished upgrade of"
+	Activated       Result = "activated"
+	Deactivated     Result = "deactivated"
+	Restarted       Result = "restarted"
+	// Pending is an upgrade waiting for the operator to finish it
+	Pending Result = "awaiting confirmation of"
+)
+
+var resultOrder = []Result{
+	Created,
+	Upgraded,
+	RolledBack,
+	FinishedUpgrade,
+	Pending,
+	Activated,
+	Deactivated,
+	Restarted,
+	Applied,
+	Removed,
+	Unchanged,
+}
+
+// Progress collects the state of every resource touched while deploying a
+// stack. All methods are safe to call on a nil Progress.
+type Progress struct {
+	sync.Mutex
+
+	defaultMessage string
+	active         []string
+	messages       map[string]string
+	results        map[string]Result
+	version        int
+}
+
+func New(defaultMessage string) *Progress {
+	return &Progress{
+		defaultMessage: defaultMessage,
+		messages:       map[string]string{},
+		results:        map[string]Result{},
+	}
+}
+
+// Update sets the in-flight message of a resource, such as
+// "Upgrading service web (2/5)"
+func (p *Progress) Update(kind, name, message string) {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	key := resourceKey(kind, name)
+	if _, ok := p.messages[key]; !ok {
+		p.active = append(p.active, key)
+	}
+	if p.messages[key] != message {
+		p.messages[key] = message
+		p.version++
+	}
+}
+
+// Done records the result of a resource and clears its in-flight message.
+// A resource keeps its most significant result, so a service that is created
+// and then found unchanged when started is reported as created.
+func (p *Progress) Done(kind, name string, result Result) {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	key := resourceKey(kind, name)
+	p.clear(key)
+	if existing, ok := p.results[key]; !ok || rank(result) < rank(existing) {
+		p.results[key] = result
+	}
+	p.version++
+}
+
+// Clear removes the in-flight message of a resource without recording a result
+func (p *Progress) Clear(kind, name string) {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.clear(resourceKey(kind, name))
+	p.version++
+}
+
+func (p *Progress) clear(key string) {
+	if _, ok := p.messages[key]; !ok {
+		return
+	}
+	delete(p.messages, key)
+	for i, active := range p.active {
+		if active == key {
+			p.active = append(p.active[:i], p.active[i+1:]...)
+			break
+		}
+	}
+}
+
+// Message returns the in-flight messages of all resources joined together,
+// or the default message when nothing is in progress. The returned version
+// changes every time the progress does.
+func (p *Progress) Message() (string, int) {
+	if p == nil {
+		return "", 0
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.active) == 0 {
+		return p.defaultMessage, p.version
+	}
+
+	var parts []string
+	for i, key := range p.active {
+		message := p.messages[key]
+		if i > 0 {
+			message = lowerFirst(message)
+		}
+		parts = append(parts, message)
+	}
+
+	return strings.Join(parts, ", "), p.version
+}
+
+// Summary lists the resources by result, for example
+// "Created volume data, service web; unchanged secret password"
+func (p *Progress) Summary() string {
+	if p == nil {
+		return ""
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	byResult := map[Result][]string{}
+	for key, result := range p.results {
+		byResult[result] = append(byResult[result], key)
+	}
+
+	var parts []string
+	for _, result := range resultOrder {
+		keys := byResult[result]
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Strings(keys)
+		parts = append(parts, fmt.Sprintf("%s %s", result, strings.Join(keys, ", ")))
+	}
+
+	if len(parts) == 0 {
+		return "No changes"
+	}
+
+	summary := strings.Join(parts, "; ")
+	return upperFirst(summary)
+}
+
+func rank(result Result) int {
+	for i, r := range resultOrder {
+		if r == result {
+			return i
+		}
+	}
+	return len(resultOrder)
+}
+
+func resourceKey(kind, name string) string {
+	return fmt.Sprintf("%s %s", kind, name)
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
@@ -0,0 +1,53 @@
+package progress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage(t *testing.T) {
+	p := New("Creating stack")
+
+	message, version := p.Message()
+	assert.Equal(t, "Creating stack", message)
+
+	p.Update("service", "web", "Upgrading service web (2/5)")
+	p.Update("image", "nginx", "Pulling image nginx on 3 hosts")
+	message, next := p.Message()
+	assert.Equal(t, "Upgrading service web (2/5), pulling image nginx on 3 hosts", message)
+	assert.NotEqual(t, version, next)
+
+	p.Update("service", "web", "Upgrading service web (2/5)")
+	_, same := p.Message()
+	assert.Equal(t, next, same)
+
+	p.Clear("image", "nginx")
+	p.Done("service", "web", Upgraded)
+	message, _ = p.Message()
+	assert.Equal(t, "Creating stack", message)
+}
+
+func TestSummary(t *testing.T) {
+	p := New("")
+	assert.Equal(t, "No changes", p.Summary())
+
+	p.Done("service", "web", Created)
+	p.Done("service", "web", Unchanged)
+	p.Done("volume", "data", Created)
+	p.Done("secret", "password", Unchanged)
+	p.Done("service", "db", Upgraded)
+
+	assert.Equal(t, "Created service web, volume data; upgraded service db; unchanged secret password", p.Summary())
+}
+
+func TestNil(t *testing.T) {
+	var p *Progress
+	p.Update("service", "web", "Creating service web")
+	p.Done("service", "web", Created)
+	p.Clear("service", "web")
+
+	message, _ := p.Message()
+	assert.Equal(t, "", message)
+	assert.Equal(t, "", p.Summary())
+}
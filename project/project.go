@@ -2,15 +2,19 @@ package project
 
 import (
 	"fmt"
+	"sync"
 
 	"golang.org/x/net/context"
 
 	"github.com/rancher/go-rancher/catalog"
 	"github.com/rancher/go-rancher/v3"
 	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/lookup"
 	"github.com/rancher/rancher-compose-executor/parser"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
+	"github.com/rancher/rancher-compose-executor/redact"
 )
 
 var resourceFactories = []ResourceFactory{}
@@ -30,10 +34,14 @@ type Project struct {
 	ServerResourceLookup lookup.ServerResourceLookup
 	Project              *Project
 	TemplateVersion      *catalog.TemplateVersion
+	Progress             *progress.Progress
 
 	Client  *client.RancherClient
 	Stack   *client.Stack
 	Cluster *client.Cluster
+
+	upgradedLock sync.Mutex
+	upgraded     []string
 }
 
 func NewProject(name string, client *client.RancherClient, cluster *client.Cluster) *Project {
@@ -45,11 +53,48 @@ func NewProject(name string, client *client.RancherClient, cluster *client.Clust
 	}
 }
 
-func (p *Project) load(file string, bytes []byte) error {
-	config, err := parser.Merge(p.Config.Services, p.Answers, p.ResourceLookup, p.TemplateVersion, p.Cluster, file, bytes)
+// NewOfflineProject returns a project that is never bound to a server. Its
+// stack and server side references are placeholders, so that templates can
+// be parsed and converted to payloads without a Rancher server.
+func NewOfflineProject(name string, cluster *client.Cluster) *Project {
+	if cluster == nil {
+		cluster = &client.Cluster{}
+	}
+	p := NewProject(name, nil, cluster)
+	p.Stack = &client.Stack{
+		Resource: client.Resource{
+			Id: "${stack}",
+		},
+		Name: name,
+	}
+	p.ServerResourceLookup = &lookup.ReferenceLookup{}
+	return p
+}
+
+// RecordUpgrade records that a service or container was upgraded to a new
+// revision by this project
+func (p *Project) RecordUpgrade(name string) {
+	p.upgradedLock.Lock()
+	defer p.upgradedLock.Unlock()
+	p.upgraded = append(p.upgraded, name)
+}
+
+// Upgraded returns the services and containers upgraded by this project, in
+// the order they were upgraded
+func (p *Project) Upgraded() []string {
+	p.upgradedLock.Lock()
+	defer p.upgradedLock.Unlock()
+	return append([]string{}, p.upgraded...)
+}
+
+func (p *Project) load(ctx context.Context, file string, bytes []byte) error {
+	config, err := parser.Merge(ctx, p.Config.Services, p.Answers, p.ResourceLookup, p.TemplateVersion, p.Cluster, file, bytes)
 	if err != nil {
 		return fmt.Errorf("Could not parse config: %v", err)
 	}
+	if config.FinishUpgrade != "" {
+		p.Config.FinishUpgrade = config.FinishUpgrade
+	}
 	for name, config := range config.Services {
 		p.Config.Services[name] = config
 	}
@@ -68,10 +113,14 @@ func (p *Project) load(file string, bytes []byte) error {
 	for name, config := range config.Secrets {
 		p.Config.Secrets[name] = config
 	}
+	for name, config := range config.Certificates {
+		p.Config.Certificates[name] = config
+	}
 	for name, config := range config.Hosts {
 		p.Config.Hosts[name] = config
 	}
 	for name, resource := range config.KubernetesResources {
+		redact.RegisterManifest(resource)
 		p.Config.KubernetesResources[name] = resource
 	}
 
@@ -87,6 +136,10 @@ func (p *Project) create(ctx context.Context, options options.Options, start boo
 		return fmt.Errorf("no-recreate and force-recreate cannot be combined")
 	}
 
+	if err := p.checkSelected(options.Services); err != nil {
+		return err
+	}
+
 	var resources []ResourceSet
 	for _, factory := range resourceFactories {
 		resourceSet, err := factory(p)
@@ -96,13 +149,15 @@ func (p *Project) create(ctx context.Context, options options.Options, start boo
 		resources = append(resources, resourceSet)
 	}
 
+	initializeCtx := logging.WithField(ctx, logging.Phase, "create")
 	for _, resource := range resources {
-		if err := resource.Initialize(ctx, options); err != nil {
+		if err := resource.Initialize(initializeCtx, options); err != nil {
 			return err
 		}
 	}
 
 	if start {
+		ctx = logging.WithField(ctx, logging.Phase, "up")
 		for _, resource := range resources {
 			if starter, ok := resource.(Starter); ok {
 				if err := starter.Start(ctx, options); err != nil {
@@ -114,3 +169,37 @@ func (p *Project) create(ctx context.Context, options options.Options, start boo
 
 	return nil
 }
+
+// Action applies a stack wide action to the resource sets that support it
+func (p *Project) Action(ctx context.Context, action Action, options options.Options) error {
+	if err := p.checkSelected(options.Services); err != nil {
+		return err
+	}
+
+	ctx = logging.WithField(ctx, logging.Phase, string(action))
+	for _, factory := range resourceFactories {
+		resourceSet, err := factory(p)
+		if err != nil {
+			return err
+		}
+		if actioner, ok := resourceSet.(Actioner); ok {
+			if err := actioner.Action(ctx, action, options); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkSelected fails if a selected service is neither a service nor a
+// container of the project
+func (p *Project) checkSelected(services []string) error {
+	for _, name := range services {
+		_, isService := p.Config.Services[name]
+		_, isContainer := p.Config.Containers[name]
+		if !isService && !isContainer {
+			return fmt.Errorf("No such service: %s", name)
+		}
+	}
+	return nil
+}
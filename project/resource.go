@@ -14,5 +14,21 @@ type Starter interface {
 	Start(ctx context.Context, options options.Options) error
 }
 
-// Optionally ResourceSet can implement Starter
+// Action is applied to every resource of a stack at once
+type Action string
+
+const (
+	RollbackAction      Action = "rollback"
+	FinishUpgradeAction Action = "finishupgrade"
+	ActivateAction      Action = "activate"
+	DeactivateAction    Action = "deactivate"
+	RestartAction       Action = "restart"
+	RemoveAction        Action = "remove"
+)
+
+type Actioner interface {
+	Action(ctx context.Context, action Action, options options.Options) error
+}
+
+// Optionally ResourceSet can implement Starter and Actioner
 type ResourceFactory func(p *Project) (ResourceSet, error)
@@ -0,0 +1,220 @@
+// Package redact masks credentials and secret material in text that leaves
+// the process, such as log lines and the transitioning messages of stacks.
+package redact
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	Mask = "[REDACTED]"
+
+	// minLength keeps short values, which would mask unrelated text, from
+	// being registered
+	minLength = 4
+	// minLineLength is the length from which single lines of multi-line
+	// values are registered, when they look like a token rather than like
+	// a line of configuration
+	minLineLength = 16
+	// maxValues bounds the registered values, the ones expiring first are
+	// dropped beyond it
+	maxValues = 10000
+)
+
+var (
+	// Expiry is how long a registered value is masked for after its last
+	// registration
+	Expiry = time.Hour
+
+	lock sync.RWMutex
+	// values holds the expiry of every registered value, zero for values
+	// that never expire
+	values = map[string]time.Time{}
+	// sorted holds the registered values longest first, so that a value
+	// containing another is masked whole
+	sorted []string
+
+	patterns = []struct {
+		re          *regexp.Regexp
+		replacement string
+	}{
+		// Authorization headers and kubeconfig tokens
+		{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`), "${1}" + Mask},
+		// Credentials in URLs
+		{regexp.MustCompile(`(://)[^/\s:@]+:[^/\s@]+@`), "${1}" + Mask + "@"},
+		// Keys, tokens and passwords as JSON fields, YAML keys or query parameters
+		{regexp.MustCompile(`(?i)("?(?:secret_?key|access_?key|api_?key|token|password|bearer_?token)"?\s*[:=]\s*"?)[^"\s,&}]+`), "${1}" + Mask},
+		// PEM private keys
+		{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), Mask},
+	}
+)
+
+// Register marks values as secret, so that String masks them wherever they
+// appear until they expire. Base64 encoded values are masked in their
+// decoded form too.
+func Register(secrets ...string) {
+	registerAll(time.Now().Add(Expiry), secrets)
+}
+
+// RegisterCredentials marks the credentials of the process as secret, which
+// never expire
+func RegisterCredentials(secrets ...string) {
+	registerAll(time.Time{}, secrets)
+}
+
+func registerAll(expires time.Time, secrets []string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, secret := range secrets {
+		register(secret, expires)
+		if decoded, err := base64.StdEncoding.DecodeString(secret); err == nil && utf8.Valid(decoded) {
+			register(string(decoded), expires)
+		}
+	}
+	prune(time.Now())
+}
+
+// register adds a value, and the lines of a multi-line value that look like
+// tokens, as some output only shows part of a value
+func register(secret string, expires time.Time) {
+	add(strings.TrimSpace(secret), expires)
+	if !strings.Contains(secret, "\n") {
+		return
+	}
+	for _, line := range strings.Split(secret, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) >= minLineLength && strings.IndexFunc(line, unicode.IsSpace) < 0 {
+			add(line, expires)
+		}
+	}
+}
+
+func add(value string, expires time.Time) {
+	if len(value) < minLength {
+		return
+	}
+	if current, ok := values[value]; ok && (current.IsZero() || current.After(expires)) {
+		return
+	}
+	values[value] = expires
+}
+
+// prune drops expired values and the ones expiring first beyond maxValues,
+// and sorts the remaining ones
+func prune(now time.Time) {
+	expiring := make([]string, 0, len(values))
+	for value, expires := range values {
+		if expires.IsZero() {
+			continue
+		}
+		if expires.Before(now) {
+			delete(values, value)
+			continue
+		}
+		expiring = append(expiring, value)
+	}
+	if excess := len(values) - maxValues; excess > 0 {
+		sort.Slice(expiring, func(i, j int) bool {
+			return values[expiring[i]].Before(values[expiring[j]])
+		})
+		if excess > len(expiring) {
+			excess = len(expiring)
+		}
+		for _, value := range expiring[:excess] {
+			delete(values, value)
+		}
+	}
+
+	result := make([]string, 0, len(values))
+	for value := range values {
+		result = append(result, value)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return len(result[i]) > len(result[j])
+	})
+	sorted = result
+}
+
+// RegisterManifest registers the values of a Kubernetes manifest of kind
+// Secret
+func RegisterManifest(manifest interface{}) {
+	m := toMap(manifest)
+	if m == nil || m["kind"] != "Secret" {
+		return
+	}
+	for _, key := range []string{"data", "stringData"} {
+		for _, value := range toMap(m[key]) {
+			if s, ok := value.(string); ok {
+				Register(s)
+			}
+		}
+	}
+}
+
+// String masks registered values and anything that looks like a credential
+func String(s string) string {
+	for _, pattern := range patterns {
+		s = pattern.re.ReplaceAllString(s, pattern.replacement)
+	}
+
+	lock.RLock()
+	secrets := sorted
+	lock.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, Mask, -1)
+	}
+	return s
+}
+
+// Formatter redacts the message and fields of log entries before the
+// formatter it wraps escapes them
+type Formatter struct {
+	Formatter logrus.Formatter
+}
+
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	redacted := *entry
+	redacted.Message = String(entry.Message)
+	redacted.Data = make(logrus.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			redacted.Data[key] = String(v)
+		case error:
+			redacted.Data[key] = String(v.Error())
+		case fmt.Stringer:
+			redacted.Data[key] = String(v.String())
+		default:
+			redacted.Data[key] = value
+		}
+	}
+	return f.Formatter.Format(&redacted)
+}
+
+func toMap(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for k, value := range v {
+			if key, ok := k.(string); ok {
+				result[key] = value
+			}
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package resources
+
+import (
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/rancher/rancher-compose-executor/project/options"
+	rutils "github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
+)
+
+// Action applies a stack wide action to the selected services and
+// containers one at a time. Services are activated, restarted and their
+// upgrades finished in dependency order, and deactivated, rolled back and
+// removed in reverse so that dependents stop using a service before it
+// changes.
+func (s *Services) Action(ctx context.Context, action project.Action, options options.Options) error {
+	order := s.ServiceOrder
+	switch action {
+	case project.RollbackAction, project.DeactivateAction, project.RemoveAction:
+		order = make([]string, 0, len(s.ServiceOrder))
+		for i := len(s.ServiceOrder) - 1; i >= 0; i-- {
+			order = append(order, s.ServiceOrder[i])
+		}
+	}
+
+	for _, name := range order {
+		if !rutils.IsSelected(options.Services, name) {
+			continue
+		}
+		actionCtx, cancel := context.WithTimeout(ctx, s.startTimeout())
+		err := s.Services[name].Action(actionCtx, action, options)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
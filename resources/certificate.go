@@ -0,0 +1,148 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
+	"github.com/rancher/rancher-compose-executor/redact"
+)
+
+const (
+	pemPrefix = "-----BEGIN"
+
+	// ownerDescription marks certificates created by a stack, as
+	// certificates have no stack or labels of their own
+	ownerDescription = "Managed by stack %s"
+)
+
+func CertificatesCreate(p *project.Project) (project.ResourceSet, error) {
+	certificates := make([]*Certificate, 0, len(p.Config.Certificates))
+	for name, config := range p.Config.Certificates {
+		certificates = append(certificates, &Certificate{
+			project: p,
+			name:    name,
+			config:  config,
+		})
+	}
+	return &Certificates{
+		certificates: certificates,
+	}, nil
+}
+
+type Certificates struct {
+	certificates []*Certificate
+}
+
+func (c *Certificates) Initialize(ctx context.Context, _ options.Options) error {
+	for _, certificate := range c.certificates {
+		if err := certificate.EnsureItExists(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Certificate struct {
+	project *project.Project
+	name    string
+	config  *config.CertificateConfig
+}
+
+// EnsureItExists creates the certificate, or updates it when the content
+// in the template no longer matches the one in Rancher. Certificates are
+// looked up by name across the environment, so one created otherwise than
+// by this stack is never touched.
+func (c *Certificate) EnsureItExists(ctx context.Context) error {
+	desired, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.project.ServerResourceLookup.Cert(c.name)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		logging.FromContext(ctx).Infof("Creating certificate %s", c.name)
+		c.project.Progress.Update("certificate", c.name, fmt.Sprintf("Creating certificate %s", c.name))
+		if _, err := c.project.Client.Certificate.Create(desired); err != nil {
+			return err
+		}
+		c.project.Progress.Done("certificate", c.name, progress.Created)
+		return nil
+	}
+
+	if existing.Description != desired.Description {
+		return fmt.Errorf("Certificate %s already exists and isn't managed by stack %s", c.name, c.project.Stack.Name)
+	}
+
+	if !certificateChanged(existing, desired) {
+		logging.FromContext(ctx).Infof("Certificate %s already exists", c.name)
+		c.project.Progress.Done("certificate", c.name, progress.Unchanged)
+		return nil
+	}
+
+	logging.FromContext(ctx).Infof("Updating certificate %s", c.name)
+	c.project.Progress.Update("certificate", c.name, fmt.Sprintf("Updating certificate %s", c.name))
+	if _, err := c.project.Client.Certificate.Update(existing, map[string]interface{}{
+		"cert":      desired.Cert,
+		"key":       desired.Key,
+		"certChain": desired.CertChain,
+	}); err != nil {
+		return err
+	}
+	c.project.Progress.Done("certificate", c.name, progress.Upgraded)
+	return nil
+}
+
+func (c *Certificate) load() (*client.Certificate, error) {
+	cert, err := c.content(c.config.Cert)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.content(c.config.Key)
+	if err != nil {
+		return nil, err
+	}
+	redact.Register(key)
+	chain, err := c.content(c.config.Chain)
+	if err != nil {
+		return nil, err
+	}
+	return &client.Certificate{
+		Name:        c.name,
+		Description: fmt.Sprintf(ownerDescription, c.project.Stack.Id),
+		Cert:        cert,
+		Key:         key,
+		CertChain:   chain,
+	}, nil
+}
+
+// content returns PEM content as is and reads anything else as a file
+func (c *Certificate) content(value string) (string, error) {
+	if value == "" || strings.HasPrefix(strings.TrimSpace(value), pemPrefix) {
+		return value, nil
+	}
+	// The parser already resolved the file relative to the project directory
+	contents, _, err := c.project.ResourceLookup.Lookup(value, "")
+	if err != nil {
+		return "", fmt.Errorf("Failed to read %s of certificate %s: %v", value, c.name, err)
+	}
+	return string(contents), nil
+}
+
+// certificateChanged compares the certificate and chain only, as Rancher
+// never returns the key. A new key always comes with a new certificate.
+func certificateChanged(existing, desired *client.Certificate) bool {
+	return strings.TrimSpace(existing.Cert) != strings.TrimSpace(desired.Cert) ||
+		strings.TrimSpace(existing.CertChain) != strings.TrimSpace(desired.CertChain)
+}
@@ -7,10 +7,11 @@ import (
 
 	"golang.org/x/net/context"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 )
 
 func HostsCreate(p *project.Project) (project.ResourceSet, error) {
@@ -117,6 +118,7 @@ func (h *Host) EnsureItExists(ctx context.Context) error {
 	existingNames := map[string]bool{}
 	for _, existingHost := range existingHosts.Data {
 		existingNames[existingHost.Name] = true
+		h.project.Progress.Done("host", existingHost.Name, progress.Unchanged)
 	}
 
 	var hostsToCreate []map[string]interface{}
@@ -132,10 +134,13 @@ func (h *Host) EnsureItExists(ctx context.Context) error {
 	}
 
 	for _, host := range hostsToCreate {
-		log.Infof("Creating host %s", host["name"])
+		name := fmt.Sprint(host["name"])
+		logging.FromContext(ctx).Infof("Creating host %s", name)
+		h.project.Progress.Update("host", name, fmt.Sprintf("Creating host %s", name))
 		if err = h.project.Client.Create("host", host, &client.Host{}); err != nil {
 			return err
 		}
+		h.project.Progress.Done("host", name, progress.Created)
 	}
 
 	return nil
@@ -7,6 +7,7 @@ func init() {
 		DependenciesCreate,
 		HostsCreate,
 		SecretsCreate,
+		CertificatesCreate,
 		VolumesCreate,
 		ServicesCreate,
 		KubernetesResourcesCreate,
@@ -1,12 +1,15 @@
 package resources
 
 import (
+	"fmt"
+
 	"golang.org/x/net/context"
 
 	"github.com/rancher/go-rancher/v3"
 	"github.com/rancher/rancher-compose-executor/kubectl"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 	"os"
 )
 
@@ -20,6 +23,7 @@ func KubernetesResourcesCreate(p *project.Project) (project.ResourceSet, error)
 		return nil, err
 	}
 	return &KubernetesResources{
+		project:   p,
 		resources: p.Config.KubernetesResources,
 		cluster:   p.Cluster,
 		endpoint:  endpoint,
@@ -28,6 +32,7 @@ func KubernetesResourcesCreate(p *project.Project) (project.ResourceSet, error)
 }
 
 type KubernetesResources struct {
+	project   *project.Project
 	resources map[string]interface{}
 	cluster   *client.Cluster
 	endpoint  string
@@ -46,9 +51,11 @@ func (h *KubernetesResources) Initialize(ctx context.Context, _ options.Options)
 	defer os.Remove(kubeconfigLocation)
 
 	for name, resource := range h.resources {
-		if err := kubectl.Apply(kubeconfigLocation, name, h.namespace, resource); err != nil {
+		h.project.Progress.Update("kubernetes resource", name, fmt.Sprintf("Applying Kubernetes resource %s", name))
+		if err := kubectl.Apply(ctx, kubeconfigLocation, name, h.namespace, resource); err != nil {
 			return err
 		}
+		h.project.Progress.Done("kubernetes resource", name, progress.Applied)
 	}
 	return nil
 }
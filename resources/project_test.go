@@ -0,0 +1,443 @@
+package resources
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/convert"
+	"github.com/rancher/rancher-compose-executor/fakecattle"
+	"github.com/rancher/rancher-compose-executor/lookup"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+const testCompose = `
+version: "2"
+services:
+  web:
+    image: nginx:${VERSION}
+    scale: 2
+  db:
+    image: mysql
+secrets:
+  password:
+    file: ./password.txt
+volumes:
+  data:
+    driver: local
+`
+
+const blueGreenCompose = `
+version: "2"
+services:
+  web:
+    image: nginx:${VERSION}
+    upgrade_strategy:
+      strategy: blue_green
+`
+
+const pruneCompose = `
+version: "2"
+services:
+  web:
+    image: nginx
+    labels:
+      io.rancher.sidekicks: side
+  side:
+    image: busybox
+  db:
+    image: mysql
+`
+
+func loadTestProject(t *testing.T, s *fakecattle.Server, version string) *project.Project {
+	return loadProject(t, s, testCompose, version)
+}
+
+func loadProject(t *testing.T, s *fakecattle.Server, compose, version string) *project.Project {
+	c, err := s.Client()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	p := project.NewProject("test", c, &client.Cluster{})
+	p.Progress = progress.New("Creating stack")
+	p.ResourceLookup = &lookup.MemoryResourceLookup{
+		Content: map[string][]byte{
+			"password.txt": []byte("s3cr3t"),
+			"web-1.crt":    []byte(testCert1),
+			"web-2.crt":    []byte(testCert2),
+			"web.key":      []byte(testKey),
+		},
+	}
+	if !assert.NoError(t, p.Load(context.Background(), map[string]string{
+		"compose.yml": compose,
+	}, map[string]string{
+		"VERSION": version,
+	})) {
+		t.FailNow()
+	}
+	return p
+}
+
+func TestProjectUp(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	p := loadTestProject(t, s, "1.12")
+	assert.NoError(t, p.Up(context.Background(), options.Options{}))
+	assert.Equal(t, "Created secret password, service db, service web, volume data", p.Progress.Summary())
+
+	var web client.Service
+	if assert.True(t, s.Find("service", "web", &web)) {
+		assert.Equal(t, "active", web.State)
+		assert.Equal(t, p.Stack.Id, web.StackId)
+		assert.Equal(t, "nginx:1.12", web.LaunchConfig.Image)
+		assert.Equal(t, int64(2), web.Scale)
+	}
+
+	var secret client.Secret
+	if assert.True(t, s.Find("secret", "password", &secret)) {
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("s3cr3t")), secret.Value)
+	}
+
+	var volume client.VolumeTemplate
+	if assert.True(t, s.Find("volumeTemplate", "data", &volume)) {
+		assert.Equal(t, "local", volume.Driver)
+	}
+
+	// Deploying the same templates changes nothing
+	p = loadTestProject(t, s, "1.12")
+	assert.NoError(t, p.Up(context.Background(), options.Options{}))
+	assert.Equal(t, 1, s.Count("stack"))
+	assert.Equal(t, 2, s.Count("service"))
+	assert.Empty(t, p.Upgraded())
+}
+
+func TestProjectUpgrade(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	p := loadTestProject(t, s, "1.12")
+	if !assert.NoError(t, p.Up(context.Background(), options.Options{})) {
+		return
+	}
+
+	p = loadTestProject(t, s, "1.13")
+	assert.NoError(t, p.Up(context.Background(), options.Options{}))
+	assert.Equal(t, []string{"web"}, p.Upgraded())
+
+	var web client.Service
+	if assert.True(t, s.Find("service", "web", &web)) {
+		assert.Equal(t, "upgraded", web.State)
+		assert.Equal(t, "nginx:1.13", web.LaunchConfig.Image)
+		assert.NotEmpty(t, web.PreviousRevisionId)
+	}
+
+	p = loadTestProject(t, s, "1.13")
+	assert.NoError(t, p.Rollback(context.Background(), options.Options{}))
+	if assert.True(t, s.Find("service", "web", &web)) {
+		assert.Equal(t, "active", web.State)
+		assert.Equal(t, "nginx:1.12", web.LaunchConfig.Image)
+	}
+}
+
+func TestProjectActions(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	p := loadTestProject(t, s, "1.12")
+	if !assert.NoError(t, p.Up(context.Background(), options.Options{})) {
+		return
+	}
+
+	var web, db client.Service
+	p = loadTestProject(t, s, "1.12")
+	assert.NoError(t, p.Deactivate(context.Background(), options.Options{
+		Services: []string{"web"},
+	}))
+	assert.True(t, s.Find("service", "web", &web))
+	assert.True(t, s.Find("service", "db", &db))
+	assert.Equal(t, "inactive", web.State)
+	assert.Equal(t, "active", db.State)
+
+	// Deploying activates inactive services
+	p = loadTestProject(t, s, "1.12")
+	assert.NoError(t, p.Up(context.Background(), options.Options{}))
+	assert.True(t, s.Find("service", "web", &web))
+	assert.Equal(t, "active", web.State)
+
+	p = loadTestProject(t, s, "1.12")
+	assert.NoError(t, p.Remove(context.Background(), options.Options{}))
+	assert.NoError(t, p.Delete(context.Background()))
+	assert.Equal(t, 0, s.Count("service"))
+}
+
+func TestProjectBlueGreen(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	p := loadProject(t, s, blueGreenCompose, "1.12")
+	if !assert.NoError(t, p.Up(context.Background(), options.Options{})) {
+		return
+	}
+
+	var blue client.Service
+	if !assert.True(t, s.Find("service", "web", &blue)) {
+		return
+	}
+	assert.NotEmpty(t, blue.Metadata[convert.ConfigHashKey])
+
+	s.Add("service", client.Service{
+		Name:    "lb",
+		Kind:    "loadBalancerService",
+		StackId: "1st99",
+		LbConfig: &client.LbConfig{
+			PortRules: []client.PortRule{{ServiceId: blue.Id}},
+		},
+	})
+	s.Add("service", client.Service{
+		Name:         "peer",
+		StackId:      p.Stack.Id,
+		ServiceLinks: []client.Link{{Name: "web", Alias: "backend"}},
+	})
+	s.Add("service", client.Service{
+		Name:         "client",
+		StackId:      "1st99",
+		ServiceLinks: []client.Link{{Name: p.Stack.Name + "/web"}},
+	})
+
+	// The config hash is stamped at creation, so deploying the same
+	// templates doesn't create a green deployment
+	p = loadProject(t, s, blueGreenCompose, "1.12")
+	assert.NoError(t, p.Up(context.Background(), options.Options{}))
+	var web client.Service
+	if assert.True(t, s.Find("service", "web", &web)) {
+		assert.Equal(t, blue.Id, web.Id)
+	}
+
+	p = loadProject(t, s, blueGreenCompose, "1.13")
+	assert.NoError(t, p.Up(context.Background(), options.Options{}))
+	assert.Equal(t, 4, s.Count("service"))
+
+	var green, lb, peer, other client.Service
+	assert.False(t, s.Find("service", "web-green", &green))
+	if assert.True(t, s.Find("service", "web", &green)) {
+		assert.NotEqual(t, blue.Id, green.Id)
+		assert.Equal(t, "nginx:1.13", green.LaunchConfig.Image)
+	}
+	if assert.True(t, s.Find("service", "lb", &lb)) {
+		assert.Equal(t, green.Id, lb.LbConfig.PortRules[0].ServiceId)
+	}
+	if assert.True(t, s.Find("service", "peer", &peer)) {
+		assert.Equal(t, []client.Link{{Name: "web", Alias: "backend"}}, peer.ServiceLinks)
+	}
+	if assert.True(t, s.Find("service", "client", &other)) {
+		assert.Equal(t, []client.Link{{Name: p.Stack.Name + "/web"}}, other.ServiceLinks)
+	}
+}
+
+func TestProjectBlueGreenLeftover(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	p := loadProject(t, s, blueGreenCompose, "1.12")
+	if !assert.NoError(t, p.Up(context.Background(), options.Options{})) {
+		return
+	}
+
+	c, err := s.Client()
+	if !assert.NoError(t, err) {
+		return
+	}
+	var blue client.Service
+	if !assert.True(t, s.Find("service", "web", &blue)) {
+		return
+	}
+
+	// An upgrade to the same templates switched the load balancer to
+	// green and was cut short before removing blue
+	leftover := blue
+	leftover.Id = ""
+	leftover.Name = "web-green"
+	leftoverId := s.Add("service", leftover)
+	s.Add("service", client.Service{
+		Name:    "lb",
+		Kind:    "loadBalancerService",
+		StackId: p.Stack.Id,
+		LbConfig: &client.LbConfig{
+			PortRules: []client.PortRule{{ServiceId: leftoverId}},
+		},
+	})
+	_, err = c.Service.Update(&blue, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			convert.ConfigHashKey: "stale",
+		},
+	})
+	assert.NoError(t, err)
+
+	p = loadProject(t, s, blueGreenCompose, "1.12")
+	assert.NoError(t, p.Up(context.Background(), options.Options{}))
+
+	var web, lb client.Service
+	assert.False(t, s.Find("service", "web-green", &web))
+	if assert.True(t, s.Find("service", "web", &web)) {
+		assert.Equal(t, leftoverId, web.Id)
+	}
+	if assert.True(t, s.Find("service", "lb", &lb)) {
+		assert.Equal(t, leftoverId, lb.LbConfig.PortRules[0].ServiceId)
+	}
+	assert.Equal(t, 2, s.Count("service"))
+}
+
+func TestProjectPausedService(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	p := loadTestProject(t, s, "1.12")
+	if !assert.NoError(t, p.Up(context.Background(), options.Options{})) {
+		return
+	}
+
+	var web client.Service
+	if !assert.True(t, s.Find("service", "web", &web)) {
+		return
+	}
+	s.SetState("service", web.Id, "paused")
+
+	// Only upgrades paused by a canary are resumed
+	p = loadTestProject(t, s, "1.12")
+	assert.Error(t, p.Up(context.Background(), options.Options{}))
+	assert.True(t, s.Find("service", "web", &web))
+	assert.Equal(t, "paused", web.State)
+}
+
+func TestProjectPrune(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	p := loadProject(t, s, pruneCompose, "")
+	if !assert.NoError(t, p.Up(context.Background(), options.Options{Prune: true})) {
+		return
+	}
+	assert.Equal(t, 2, s.Count("service"))
+
+	var web client.Service
+	if !assert.True(t, s.Find("service", "web", &web)) {
+		return
+	}
+	s.Add("service", client.Service{
+		Name:    "old",
+		StackId: p.Stack.Id,
+	})
+	s.Add("service", client.Service{
+		Name:    "kept",
+		StackId: p.Stack.Id,
+		LaunchConfig: &client.LaunchConfig{
+			Labels: map[string]string{"io.rancher.compose.prune": "false"},
+		},
+	})
+	s.Add("service", client.Service{
+		Name:    "other",
+		StackId: "1st99",
+	})
+	s.Add("container", client.Container{
+		Name:    "old-container",
+		StackId: p.Stack.Id,
+	})
+	s.Add("container", client.Container{
+		Name:    "kept-container",
+		StackId: p.Stack.Id,
+		Labels:  map[string]string{"io.rancher.compose.prune": "false"},
+	})
+	s.Add("container", client.Container{
+		Name:      "web-1",
+		StackId:   p.Stack.Id,
+		ServiceId: web.Id,
+	})
+
+	// Selective deploys don't prune
+	p = loadProject(t, s, pruneCompose, "")
+	assert.NoError(t, p.Up(context.Background(), options.Options{
+		Prune:    true,
+		Services: []string{"db"},
+	}))
+	assert.Equal(t, 5, s.Count("service"))
+	assert.Equal(t, 3, s.Count("container"))
+
+	// Failed deploys don't prune
+	s.SetState("service", web.Id, "paused")
+	p = loadProject(t, s, pruneCompose, "")
+	assert.Error(t, p.Up(context.Background(), options.Options{Prune: true}))
+	assert.Equal(t, 5, s.Count("service"))
+	assert.Equal(t, 3, s.Count("container"))
+
+	s.SetState("service", web.Id, "active")
+	p = loadProject(t, s, pruneCompose, "")
+	assert.NoError(t, p.Up(context.Background(), options.Options{Prune: true}))
+
+	var service client.Service
+	var container client.Container
+	assert.False(t, s.Find("service", "old", &service))
+	assert.True(t, s.Find("service", "kept", &service))
+	assert.True(t, s.Find("service", "other", &service))
+	assert.False(t, s.Find("container", "old-container", &container))
+	assert.True(t, s.Find("container", "kept-container", &container))
+	assert.True(t, s.Find("container", "web-1", &container))
+
+	// The sidekick is deployed as part of web, which stays
+	if assert.True(t, s.Find("service", "web", &service)) {
+		assert.Equal(t, web.Id, service.Id)
+		if assert.Len(t, service.SecondaryLaunchConfigs, 1) {
+			assert.Equal(t, "side", service.SecondaryLaunchConfigs[0].Name)
+		}
+	}
+	assert.True(t, s.Find("service", "db", &service))
+	assert.False(t, s.Find("service", "side", &service))
+	assert.Equal(t, 4, s.Count("service"))
+	assert.Equal(t, 2, s.Count("container"))
+}
+
+func TestProjectCanaryConfirm(t *testing.T) {
+	s := fakecattle.NewServer()
+	defer s.Close()
+
+	p := loadTestProject(t, s, "1.12")
+	if !assert.NoError(t, p.Up(context.Background(), options.Options{})) {
+		return
+	}
+
+	c, err := s.Client()
+	if !assert.NoError(t, err) {
+		return
+	}
+	var web client.Service
+	if !assert.True(t, s.Find("service", "web", &web)) {
+		return
+	}
+
+	// A canary upgrade paused web once its canary was healthy
+	_, err = c.Service.Update(&web, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			convert.CanaryKey: "true",
+		},
+	})
+	assert.NoError(t, err)
+	s.SetState("service", web.Id, "paused")
+
+	// Any other update leaves the canary waiting for confirmation
+	p = loadTestProject(t, s, "1.12")
+	assert.Error(t, p.Up(context.Background(), options.Options{}))
+	assert.True(t, s.Find("service", "web", &web))
+	assert.Equal(t, "paused", web.State)
+
+	p = loadTestProject(t, s, "1.12")
+	assert.NoError(t, p.FinishUpgrade(context.Background(), options.Options{}))
+	var finished client.Service
+	assert.True(t, s.Find("service", "web", &finished))
+	assert.Equal(t, "active", finished.State)
+	assert.NotContains(t, finished.Metadata, convert.CanaryKey)
+}
@@ -0,0 +1,86 @@
+package resources
+
+import (
+	"fmt"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/project/progress"
+	"golang.org/x/net/context"
+)
+
+// pruneLabel set to "false" keeps a service or container in the stack after
+// it is removed from the template
+const pruneLabel = "io.rancher.compose.prune"
+
+// prune removes the services and standalone containers of the stack that are
+// no longer part of the template
+func (s *Services) prune(ctx context.Context) error {
+	desiredServices := map[string]bool{}
+	for name := range s.Project.Config.Services {
+		// Sidekicks are deployed as part of their primaries, never as a service
+		if len(s.Project.Config.SidekickInfo.SidekickToPrimaries[name]) == 0 {
+			desiredServices[name] = true
+		}
+	}
+
+	services, err := s.Project.Client.Service.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"stackId":      s.Project.Stack.Id,
+			"removed_null": nil,
+		},
+	})
+	for services != nil && err == nil {
+		for i := range services.Data {
+			service := &services.Data[i]
+			if desiredServices[service.Name] || !prunable(service.LaunchConfig) {
+				continue
+			}
+			logging.FromContext(ctx).WithField(logging.Service, service.Name).Infof("Removing service %s, it is no longer in the template", service.Name)
+			s.Project.Progress.Update("service", service.Name, fmt.Sprintf("Removing service %s", service.Name))
+			if err := s.Project.Client.Service.Delete(service); err != nil {
+				return err
+			}
+			s.Project.Progress.Done("service", service.Name, progress.Removed)
+		}
+		services, err = services.Next()
+	}
+	if err != nil {
+		return err
+	}
+
+	containers, err := s.Project.Client.Container.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"stackId":      s.Project.Stack.Id,
+			"removed_null": nil,
+		},
+	})
+	for containers != nil && err == nil {
+		for i := range containers.Data {
+			container := &containers.Data[i]
+			// Containers of services are removed along with their service
+			if container.ServiceId != "" || s.Project.Config.Containers[container.Name] != nil {
+				continue
+			}
+			if container.Labels[pruneLabel] == "false" {
+				continue
+			}
+			logging.FromContext(ctx).WithField(logging.Service, container.Name).Infof("Removing container %s, it is no longer in the template", container.Name)
+			s.Project.Progress.Update("container", container.Name, fmt.Sprintf("Removing container %s", container.Name))
+			if err := s.Project.Client.Container.Delete(container); err != nil {
+				return err
+			}
+			s.Project.Progress.Done("container", container.Name, progress.Removed)
+		}
+		containers, err = containers.Next()
+	}
+
+	return err
+}
+
+func prunable(launchConfig *client.LaunchConfig) bool {
+	if launchConfig == nil {
+		return true
+	}
+	return fmt.Sprint(launchConfig.Labels[pruneLabel]) != "false"
+}
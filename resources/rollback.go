@@ -0,0 +1,34 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/resources/service"
+	"golang.org/x/net/context"
+)
+
+// rollback rolls back every service and container upgraded so far, most
+// recent first, after the upgrade of one of them failed
+func (s *Services) rollback(ctx context.Context, failed error) error {
+	upgraded := s.Project.Upgraded()
+	ctx = logging.WithField(ctx, logging.Phase, "rollback")
+	logging.FromContext(ctx).Errorf("%v, rolling back %d upgraded services", failed, len(upgraded))
+
+	var rolledBack []string
+	for i := len(upgraded) - 1; i >= 0; i-- {
+		name := upgraded[i]
+		ser, ok := s.Services[name].(service.Rollbacker)
+		if !ok {
+			continue
+		}
+		logging.FromContext(ctx).WithField(logging.Service, name).Infof("Rolling back %s", name)
+		if err := ser.Rollback(ctx); err != nil {
+			return fmt.Errorf("%v, rolling back %s failed: %v", failed, name, err)
+		}
+		rolledBack = append(rolledBack, name)
+	}
+
+	return fmt.Errorf("%v, rolled back %s", failed, strings.Join(rolledBack, ", "))
+}
@@ -0,0 +1,49 @@
+package resources
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/rancher/rancher-compose-executor/resources/service"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestRollbackCause(t *testing.T) {
+	p := project.NewOfflineProject("test", nil)
+	p.Config.Services["web"] = &config.ServiceConfig{}
+	p.Config.Services["db"] = &config.ServiceConfig{}
+	s := &Services{Project: p}
+
+	other := errors.New("Failed to create service db")
+	failed := &service.UpgradeFailedError{Service: "web", Err: errors.New("unhealthy")}
+
+	assert.Nil(t, s.rollbackCause(nil))
+	assert.Nil(t, s.rollbackCause([]error{other}))
+	// The upgrade failure is found even when a sibling failed first
+	assert.Equal(t, failed, s.rollbackCause([]error{other, failed}))
+
+	p.Config.Services["web"].UpgradeStrategy.RollbackOnFailure = true
+	assert.Equal(t, other, s.rollbackCause([]error{other}))
+}
+
+func TestRollbackNamesService(t *testing.T) {
+	p := project.NewOfflineProject("test", nil)
+	p.Config.Services["web"] = &config.ServiceConfig{}
+	p.Config.Services["db"] = &config.ServiceConfig{}
+	p.Config.Services["db"].UpgradeStrategy.RollbackOnFailure = true
+	s := &Services{Project: p}
+
+	failed := &service.UpgradeFailedError{Service: "web", Err: errors.New("unhealthy")}
+	assert.Equal(t, failed, serviceError("web", failed))
+	assert.Nil(t, serviceError("web", nil))
+
+	// The service the rollback is triggered by is named in its error
+	cause := s.rollbackCause([]error{serviceError("db", errors.New("timeout"))})
+	err := s.rollback(context.Background(), cause)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Service db: timeout")
+	}
+}
@@ -6,10 +6,12 @@ import (
 
 	"golang.org/x/net/context"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
+	"github.com/rancher/rancher-compose-executor/redact"
 )
 
 func SecretsCreate(p *project.Project) (project.ResourceSet, error) {
@@ -57,21 +59,28 @@ func (s *Secret) EnsureItExists(ctx context.Context) error {
 		return err
 	}
 	if len(existingSecrets.Data) > 0 {
-		log.Infof("Secret %s already exists", s.name)
+		logging.FromContext(ctx).Infof("Secret %s already exists", s.name)
+		s.project.Progress.Done("secret", s.name, progress.Unchanged)
 		return nil
 	}
 	if s.external != "" {
 		return fmt.Errorf("Existing secret %s not found", s.name)
 	}
-	// TODO: use real relative path
-	contents, filename, err := s.project.ResourceLookup.Lookup(s.file, "./")
+	// The parser already resolved the file relative to the project directory
+	contents, filename, err := s.project.ResourceLookup.Lookup(s.file, "")
 	if err != nil {
 		return err
 	}
-	log.Infof("Creating secret %s with contents from file %s", s.name, filename)
+	redact.Register(string(contents))
+	logging.FromContext(ctx).Infof("Creating secret %s with contents from file %s", s.name, filename)
+	s.project.Progress.Update("secret", s.name, fmt.Sprintf("Creating secret %s", s.name))
 	_, err = s.project.Client.Secret.Create(&client.Secret{
 		Name:  s.name,
 		Value: base64.StdEncoding.EncodeToString(contents),
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	s.project.Progress.Done("secret", s.name, progress.Created)
+	return nil
 }
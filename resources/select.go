@@ -0,0 +1,123 @@
+package resources
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/project/options"
+)
+
+// withSidekicks adds the sidekicks of the selected primaries to the
+// selection, as they are deployed as part of the primary service
+func (s *Services) withSidekicks(selected []string) []string {
+	if len(selected) == 0 {
+		return selected
+	}
+
+	result := map[string]bool{}
+	for _, name := range selected {
+		result[name] = true
+		for _, sidekick := range s.Project.Config.SidekickInfo.PrimariesToSidekicks[name] {
+			result[sidekick] = true
+		}
+	}
+	return sortedKeys(result)
+}
+
+// withDependencies adds to the selection the services and containers the
+// selected ones can't be deployed without: their sidekicks and what they
+// link to, mount volumes from, share a namespace with or balance load
+// across, recursively
+func (s *Services) withDependencies(selected []string) []string {
+	if len(selected) == 0 {
+		return selected
+	}
+
+	result := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if result[name] {
+			return
+		}
+		if _, ok := s.Services[name]; !ok {
+			return
+		}
+		result[name] = true
+		for _, sidekick := range s.Project.Config.SidekickInfo.PrimariesToSidekicks[name] {
+			visit(sidekick)
+		}
+		for _, dependency := range s.dependencies(name) {
+			visit(dependency)
+		}
+	}
+	for _, name := range selected {
+		visit(name)
+	}
+	return sortedKeys(result)
+}
+
+func (s *Services) dependencies(name string) []string {
+	serviceConfig, ok := s.Project.Config.Services[name]
+	if !ok {
+		serviceConfig, ok = s.Project.Config.Containers[name]
+	}
+	if !ok {
+		return nil
+	}
+
+	var result []string
+	for _, link := range serviceConfig.Links {
+		result = append(result, strings.SplitN(link, ":", 2)[0])
+	}
+	for _, volumesFrom := range serviceConfig.VolumesFrom {
+		volumesFrom = strings.TrimPrefix(strings.TrimPrefix(volumesFrom, "container:"), "service:")
+		result = append(result, strings.SplitN(volumesFrom, ":", 2)[0])
+	}
+	for _, mode := range []string{serviceConfig.NetworkMode, serviceConfig.Pid, serviceConfig.Ipc} {
+		for _, prefix := range []string{"container:", "service:"} {
+			if strings.HasPrefix(mode, prefix) {
+				result = append(result, strings.TrimPrefix(mode, prefix))
+			}
+		}
+	}
+	for dependency := range serviceConfig.DependsOn {
+		result = append(result, dependency)
+	}
+	result = append(result, lbTargets(serviceConfig.LbConfig)...)
+	return result
+}
+
+func lbTargets(lbConfig *config.LBConfig) []string {
+	if lbConfig == nil {
+		return nil
+	}
+	var result []string
+	for _, portRule := range lbConfig.PortRules {
+		if portRule.Service != "" {
+			result = append(result, portRule.Service)
+		}
+		if portRule.Container != "" {
+			result = append(result, portRule.Container)
+		}
+	}
+	return result
+}
+
+// dependencyOptions are the options of a service that is only deployed
+// because a selected service depends on it. It is started if needed but
+// never recreated.
+func dependencyOptions(options options.Options) options.Options {
+	options.ForceRecreate = false
+	options.NoRecreate = true
+	return options
+}
+
+func sortedKeys(m map[string]bool) []string {
+	result := make([]string, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,54 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDependencies(t *testing.T) {
+	p := project.NewProject("test", nil, nil)
+	p.Config.Services = map[string]*config.ServiceConfig{
+		"web": {
+			Links:       []string{"db:database"},
+			VolumesFrom: []string{"data:ro"},
+		},
+		"db":   {},
+		"data": {NetworkMode: "container:net"},
+		"log":  {},
+		"app": {
+			Labels: map[string]string{
+				"io.rancher.sidekicks": "log",
+			},
+		},
+		"lb": {
+			RancherConfig: config.RancherConfig{
+				LbConfig: &config.LBConfig{
+					PortRules: []config.PortRule{{Service: "app"}},
+				},
+			},
+		},
+	}
+	p.Config.Containers = map[string]*config.ServiceConfig{
+		"net": {},
+	}
+	p.Config.Complete()
+
+	s := &Services{
+		Project:  p,
+		Services: map[string]Service{},
+	}
+	for name := range p.Config.Services {
+		s.Services[name] = nil
+	}
+	for name := range p.Config.Containers {
+		s.Services[name] = nil
+	}
+
+	assert.Equal(t, []string{"data", "db", "net", "web"}, s.withDependencies([]string{"web"}))
+	assert.Equal(t, []string{"app", "lb", "log"}, s.withDependencies([]string{"lb"}))
+	assert.Equal(t, []string{"app", "log"}, s.withSidekicks([]string{"app"}))
+	assert.Empty(t, s.withDependencies(nil))
+}
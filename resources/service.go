@@ -3,9 +3,10 @@ package resources
 import (
 	"fmt"
 	"strings"
+	"sync"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
 	"github.com/rancher/rancher-compose-executor/resources/service"
@@ -18,6 +19,7 @@ import (
 type Service interface {
 	Create(ctx context.Context, options options.Options) error
 	Up(ctx context.Context, options options.Options) error
+	Action(ctx context.Context, action project.Action, options options.Options) error
 
 	//Config() *config.ServiceConfig
 	Name() string
@@ -64,8 +66,6 @@ func ServicesCreate(p *project.Project) (project.ResourceSet, error) {
 		return nil, err
 	}
 
-	logrus.Infof("Service order: %v", s.ServiceOrder)
-
 	return project.ResourceSet(s), nil
 }
 
@@ -98,9 +98,13 @@ func (s *Services) Initialize(ctx context.Context, options options.Options) erro
 			}
 		}
 	}*/
+	// Services are only created if they don't exist yet, so creating the
+	// dependencies of the selection leaves existing ones untouched
+	selected := s.withDependencies(options.Services)
+	logging.FromContext(ctx).Infof("Service order: %v", s.ServiceOrder)
 	for _, name := range s.ServiceOrder {
 		service := s.Services[name]
-		if rutils.IsSelected(options.Services, name) {
+		if rutils.IsSelected(selected, name) {
 			if err := service.Create(ctx, options); err != nil {
 				return err
 			}
@@ -110,20 +114,105 @@ func (s *Services) Initialize(ctx context.Context, options options.Options) erro
 }
 
 func (s *Services) Start(ctx context.Context, options options.Options) error {
-	newctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	newctx, cancel := context.WithTimeout(ctx, s.startTimeout())
 	defer cancel()
 	g, ctxTimeout := errgroup.WithContext(newctx)
+	errs := &upErrors{}
+	selected := s.withSidekicks(options.Services)
+	dependencies := s.withDependencies(options.Services)
 	for name, service := range s.Services {
-		if rutils.IsSelected(options.Services, name) {
-			g.Go(up(service, options, ctxTimeout))
+		serviceOptions := options
+		serviceOptions.Services = dependencies
+		if !rutils.IsSelected(selected, name) {
+			serviceOptions = dependencyOptions(serviceOptions)
+		}
+		if rutils.IsSelected(dependencies, name) {
+			g.Go(up(name, service, serviceOptions, ctxTimeout, errs))
+		}
+	}
+
+	if err := g.Wait(); err != nil {
+		if failed := s.rollbackCause(errs.list()); failed != nil {
+			return s.rollback(ctx, failed)
+		}
+		return err
+	}
+
+	// Only prune when the whole stack was deployed
+	if options.Prune && len(options.Services) == 0 {
+		return s.prune(ctx)
+	}
+	return nil
+}
+
+// startTimeout leaves services whose upgrade is health checked or paused
+// enough time to complete
+func (s *Services) startTimeout() time.Duration {
+	timeout := time.Second * 30
+	for _, config := range s.Project.Config.Services {
+		if upgradeTimeout := service.UpgradeTimeout(config.UpgradeStrategy) + time.Second*30; upgradeTimeout > timeout {
+			timeout = upgradeTimeout
+		}
+	}
+	return timeout
+}
+
+// rollbackCause returns the error the upgraded services are rolled back
+// for, or nil if they are kept. They are rolled back when the upgrade of a
+// service failed, or when any service failed and one of them rolls back on
+// failure.
+func (s *Services) rollbackCause(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, err := range errs {
+		if failed, ok := err.(*service.UpgradeFailedError); ok {
+			return failed
+		}
+	}
+	for _, config := range s.Project.Config.Services {
+		if config.UpgradeStrategy.RollbackOnFailure {
+			return errs[0]
 		}
 	}
+	return nil
+}
+
+// upErrors collects the errors of every service started, as the errgroup
+// only returns the first one
+type upErrors struct {
+	sync.Mutex
+	errs []error
+}
+
+func (e *upErrors) add(err error) {
+	e.Lock()
+	defer e.Unlock()
+	e.errs = append(e.errs, err)
+}
 
-	return g.Wait()
+func (e *upErrors) list() []error {
+	e.Lock()
+	defer e.Unlock()
+	return append([]error{}, e.errs...)
 }
 
-func up(ser Service, options options.Options, ctx context.Context) func() error {
+func up(name string, ser Service, options options.Options, ctx context.Context, errs *upErrors) func() error {
 	return func() error {
-		return ser.Up(ctx, options)
+		err := serviceError(name, ser.Up(ctx, options))
+		if err != nil {
+			errs.add(err)
+		}
+		return err
+	}
+}
+
+// serviceError names the service err was returned for, so the rollback it
+// triggers names it too. Upgrade failures already name their service.
+func serviceError(name string, err error) error {
+	switch err.(type) {
+	case nil, *service.UpgradeFailedError:
+		return err
 	}
+	return fmt.Errorf("Service %s: %v", name, err)
 }
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
+	"github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
+)
+
+// Action applies a stack wide action to the service. Services in a state
+// the action doesn't apply to are left unchanged.
+func (s *ServiceWrapper) Action(ctx context.Context, action project.Action, options options.Options) error {
+	service, err := s.project.ServerResourceLookup.Service(s.name)
+	if err != nil {
+		return err
+	}
+	if service == nil {
+		return fmt.Errorf("Failed to find service %s", s.name)
+	}
+
+	switch action {
+	case project.RollbackAction:
+		if service.PreviousRevisionId == "" {
+			break
+		}
+		return s.rollback(ctx, service)
+	case project.FinishUpgradeAction:
+		if pausedCanary(service) {
+			if err := s.resumeCanary(ctx, service); err != nil {
+				return err
+			}
+		}
+		if service.State != "upgraded" {
+			break
+		}
+		return s.apply(ctx, service, finishupgrade, "Finishing upgrade of", progress.FinishedUpgrade)
+	case project.ActivateAction:
+		if service.State != "inactive" {
+			break
+		}
+		return s.apply(ctx, service, activate, "Activating", progress.Activated)
+	case project.DeactivateAction:
+		if service.State == "inactive" {
+			break
+		}
+		return s.apply(ctx, service, deactivate, "Deactivating", progress.Deactivated)
+	case project.RestartAction:
+		if service.State != "active" {
+			break
+		}
+		return s.apply(ctx, service, restart, "Restarting", progress.Restarted)
+	case project.RemoveAction:
+		return s.remove(ctx, service)
+	default:
+		return fmt.Errorf("Unknown action %s", action)
+	}
+
+	s.project.Progress.Done("service", s.name, progress.Unchanged)
+	return nil
+}
+
+func (s *ServiceWrapper) apply(ctx context.Context, service *client.Service, action, verb string, result progress.Result) error {
+	s.project.Progress.Update("service", s.name, fmt.Sprintf("%s service %s", verb, s.name))
+	if err := utils.RetryOnError(10, ActionWrapper(s.project.Client, service, action)); err != nil {
+		return err
+	}
+	if err := s.wait(ctx, service, verb); err != nil {
+		return err
+	}
+	s.project.Progress.Done("service", s.name, result)
+	return nil
+}
+
+// remove deletes the service and waits for it to be gone
+func (s *ServiceWrapper) remove(ctx context.Context, service *client.Service) error {
+	s.project.Progress.Update("service", s.name, fmt.Sprintf("Removing service %s", s.name))
+	if err := s.project.Client.Service.Delete(service); err != nil {
+		return err
+	}
+	service, err := s.project.Client.Service.ById(service.Id)
+	if err != nil {
+		return err
+	}
+	if service != nil {
+		if err := s.wait(ctx, service, "Removing"); err != nil {
+			return err
+		}
+	}
+	s.project.Progress.Done("service", s.name, progress.Removed)
+	return nil
+}
+
+// Action applies a stack wide action to the container. Containers are
+// replaced as soon as they are upgraded, so there is no upgrade to finish.
+func (s *ContainerWrapper) Action(ctx context.Context, action project.Action, options options.Options) error {
+	container, err := s.project.ServerResourceLookup.Container(s.name)
+	if err != nil {
+		return err
+	}
+	if container == nil {
+		return fmt.Errorf("Failed to find container %s", s.name)
+	}
+
+	switch action {
+	case project.RollbackAction:
+		history, err := ContainerHistory(container)
+		if err != nil {
+			return err
+		}
+		if len(history) == 0 {
+			break
+		}
+		return s.rollback(ctx, container)
+	case project.FinishUpgradeAction:
+	case project.ActivateAction:
+		if container.State != "stopped" {
+			break
+		}
+		s.project.Progress.Update("container", s.name, fmt.Sprintf("Starting container %s", s.name))
+		if _, err := s.project.Client.Container.ActionStart(container); err != nil {
+			return err
+		}
+		return s.waitAction(ctx, container, progress.Activated)
+	case project.DeactivateAction:
+		if container.State != "running" {
+			break
+		}
+		s.project.Progress.Update("container", s.name, fmt.Sprintf("Stopping container %s", s.name))
+		if _, err := s.project.Client.Container.ActionStop(container, &client.InstanceStop{}); err != nil {
+			return err
+		}
+		return s.waitAction(ctx, container, progress.Deactivated)
+	case project.RestartAction:
+		if container.State != "running" {
+			break
+		}
+		s.project.Progress.Update("container", s.name, fmt.Sprintf("Restarting container %s", s.name))
+		if _, err := s.project.Client.Container.ActionRestart(container); err != nil {
+			return err
+		}
+		return s.waitAction(ctx, container, progress.Restarted)
+	case project.RemoveAction:
+		s.project.Progress.Update("container", s.name, fmt.Sprintf("Removing container %s", s.name))
+		if err := s.project.Client.Container.Delete(container); err != nil {
+			return err
+		}
+		return s.waitAction(ctx, container, progress.Removed)
+	default:
+		return fmt.Errorf("Unknown action %s", action)
+	}
+
+	s.project.Progress.Done("container", s.name, progress.Unchanged)
+	return nil
+}
+
+// waitAction waits for the container to settle after an action. Actions
+// return the instance rather than the container, so it is looked up again.
+// A removed container may already be gone.
+func (s *ContainerWrapper) waitAction(ctx context.Context, container *client.Container, result progress.Result) error {
+	container, err := s.project.Client.Container.ById(container.Id)
+	if err != nil {
+		return err
+	}
+	if container == nil && result != progress.Removed {
+		return fmt.Errorf("Failed to find container %s", s.name)
+	}
+	if container != nil {
+		if err := waitContainer(ctx, s.project.Client, container); err != nil {
+			return err
+		}
+	}
+	s.project.Progress.Done("container", s.name, result)
+	return nil
+}
+
+// Action applies a stack wide action to the primaries of the sidekick that
+// aren't selected themselves, as sidekicks are part of their primary service
+func (s *SidekickWrapper) Action(ctx context.Context, action project.Action, options options.Options) error {
+	primaries := s.getUnSelectedPrimaries(options)
+	if action == project.RemoveAction && len(primaries) > 0 {
+		return fmt.Errorf("Sidekick %s can only be removed along with %s", s.name, strings.Join(primaries, ", "))
+	}
+	for _, primary := range primaries {
+		primaryService := ServiceWrapper{
+			name:    primary,
+			project: s.project,
+		}
+		if err := primaryService.Action(ctx, action, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,13 +2,13 @@ package service
 
 import (
 	"fmt"
-	"time"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
 	"github.com/rancher/rancher-compose-executor/convert"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 	"github.com/rancher/rancher-compose-executor/utils"
 	"golang.org/x/net/context"
 )
@@ -24,17 +24,22 @@ func (s *ContainerWrapper) Exists() (bool, error) {
 }
 
 func (s *ContainerWrapper) Create(ctx context.Context, options options.Options) error {
-	container, err := convert.Container(s.project, s.name)
+	container, err := convert.Container(ctx, s.project, s.name)
 	if err != nil {
 		return err
 	}
 
-	logrus.Debugf("Creating service %s", s.name)
+	logging.FromContext(ctx).Debugf("Creating service %s", s.name)
+	s.project.Progress.Update("container", s.name, fmt.Sprintf("Creating container %s", s.name))
 	container, err = s.project.Client.Container.Create(container)
 	if err != nil {
 		return err
 	}
-	return waitContainer(ctx, s.project.Client, container)
+	if err := waitContainer(ctx, s.project.Client, container); err != nil {
+		return err
+	}
+	s.project.Progress.Done("container", s.name, progress.Created)
+	return nil
 }
 
 func (s *ContainerWrapper) Image() string {
@@ -50,47 +55,101 @@ func (s *ContainerWrapper) upgrade(ctx context.Context, container *client.Contai
 		return nil
 	}
 
-	updates, err := convert.ContainerConfig(s.project, s.name)
+	updates, err := convert.ContainerConfig(ctx, s.project, s.name)
 	if err != nil {
 		return err
 	}
 
-	rev, err := s.project.Client.Container.ActionUpgrade(container, &client.ContainerUpgrade{
-		Config: *updates,
-	})
-	if err != nil || rev == nil {
+	history, err := ContainerHistory(container)
+	if err != nil {
 		return err
 	}
+	// Keep the history as is so that it doesn't count as a change of config
+	setContainerHistory(updates, history)
 
-	for i := 0; i < 3; i++ {
-		err := waitContainer(ctx, s.project.Client, container)
-		if err != nil {
-			return err
-		}
+	s.project.Progress.Update("container", s.name, fmt.Sprintf("Upgrading container %s", s.name))
+	upgraded, err := s.upgradeTo(ctx, container, updates, append(history, currentRevision(container)))
+	if upgraded {
+		s.project.RecordUpgrade(s.name)
+	}
+	if err != nil {
+		return err
+	}
+	if !upgraded {
+		s.project.Progress.Done("container", s.name, progress.Unchanged)
+		return nil
+	}
+
+	s.project.Progress.Done("container", s.name, progress.Upgraded)
+	return nil
+}
 
-		if container.Desired {
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
+// Rollback upgrades the container back to the revision it was last upgraded from
+func (s *ContainerWrapper) Rollback(ctx context.Context) error {
+	container, err := s.project.ServerResourceLookup.Container(s.name)
+	if err != nil {
+		return err
 	}
+	if container == nil {
+		return fmt.Errorf("Failed to find container %s", s.name)
+	}
+	return s.rollback(ctx, container)
+}
 
-	containers, err := s.project.Client.Container.List(&client.ListOpts{
-		Filters: map[string]interface{}{
-			"revisionId": rev.Id,
-		},
-	})
+func (s *ContainerWrapper) rollback(ctx context.Context, container *client.Container) error {
+	history, err := ContainerHistory(container)
 	if err != nil {
 		return err
 	}
+	if len(history) == 0 {
+		return fmt.Errorf("Container %s has no previous revision to roll back to", s.name)
+	}
+	previous := history[len(history)-1]
 
-	if len(containers.Data) > 0 {
-		return waitContainer(ctx, s.project.Client, &containers.Data[0])
+	revision, err := s.project.Client.Revision.ById(previous.RevisionId)
+	if err != nil {
+		return err
+	}
+	if revision == nil || revision.Config == nil || revision.Config.LaunchConfig == nil {
+		return fmt.Errorf("Failed to find config of revision %s of container %s", previous.RevisionId, s.name)
 	}
 
+	config := client.ContainerConfig{}
+	if err := utils.Convert(revision.Config.LaunchConfig, &config); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Infof("Rolling back container %s to revision %s (%s)", s.name, previous.RevisionId, previous.Image)
+	s.project.Progress.Update("container", s.name, fmt.Sprintf("Rolling back container %s", s.name))
+	if _, err := s.upgradeTo(ctx, container, &config, history[:len(history)-1]); err != nil {
+		return err
+	}
+
+	s.project.Progress.Done("container", s.name, progress.RolledBack)
 	return nil
 }
 
+// upgradeTo upgrades the container to config, waiting for the container of
+// the new revision and recording history on it. It returns false if the
+// config didn't change.
+func (s *ContainerWrapper) upgradeTo(ctx context.Context, container *client.Container, config *client.ContainerConfig, history []ContainerRevision) (bool, error) {
+	rev, err := s.project.Client.Container.ActionUpgrade(container, &client.ContainerUpgrade{
+		Config: *config,
+	})
+	if err != nil || rev == nil {
+		return false, err
+	}
+
+	upgraded, err := waitRevision(ctx, s.project.Client, rev.Id)
+	if err != nil {
+		return true, err
+	}
+
+	_, err = s.project.Client.Container.Update(upgraded, map[string]interface{}{
+		"metadata": withContainerHistory(upgraded.Metadata, history),
+	})
+	return true, err
+}
+
 func (s *ContainerWrapper) Up(ctx context.Context, options options.Options) error {
 	container, err := s.project.ServerResourceLookup.Container(s.name)
 	if err != nil {
@@ -101,7 +160,7 @@ func (s *ContainerWrapper) Up(ctx context.Context, options options.Options) erro
 	}
 
 	if options.Rollback {
-		return nil
+		return s.rollback(ctx, container)
 	}
 
 	return s.upgrade(ctx, container, options)
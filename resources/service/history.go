@@ -0,0 +1,94 @@
+package service
+
+import (
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
+)
+
+const (
+	// containerHistoryKey is the container metadata key holding the
+	// revisions the container was upgraded from
+	containerHistoryKey = "io.rancher.container.history"
+	maxContainerHistory = 10
+)
+
+// ContainerRevision is an entry of the upgrade history of a standalone container
+type ContainerRevision struct {
+	RevisionId string `json:"revisionId"`
+	Image      string `json:"image"`
+	Deployed   string `json:"deployed"`
+}
+
+// ContainerHistory returns the revisions a container was upgraded from,
+// oldest first
+func ContainerHistory(container *client.Container) ([]ContainerRevision, error) {
+	var history []ContainerRevision
+	value, ok := container.Metadata[containerHistoryKey]
+	if !ok {
+		return nil, nil
+	}
+	err := utils.ConvertByJSON(value, &history)
+	return history, err
+}
+
+// currentRevision returns the history entry of the revision the container
+// is running now
+func currentRevision(container *client.Container) ContainerRevision {
+	return ContainerRevision{
+		RevisionId: container.RevisionId,
+		Image:      container.Image,
+		Deployed:   container.Created,
+	}
+}
+
+func setContainerHistory(config *client.ContainerConfig, history []ContainerRevision) {
+	if len(history) == 0 {
+		return
+	}
+	config.Metadata = withContainerHistory(config.Metadata, history)
+}
+
+// withContainerHistory returns a copy of the metadata holding history, keeping
+// only the most recent entries
+func withContainerHistory(metadata map[string]interface{}, history []ContainerRevision) map[string]interface{} {
+	if len(history) > maxContainerHistory {
+		history = history[len(history)-maxContainerHistory:]
+	}
+	result := map[string]interface{}{}
+	for k, v := range metadata {
+		result[k] = v
+	}
+	result[containerHistoryKey] = history
+	return result
+}
+
+// waitRevision waits for the container of a revision to be created and to
+// finish transitioning
+func waitRevision(ctx context.Context, c *client.RancherClient, revisionId string) (*client.Container, error) {
+	ticker := time.NewTicker(time.Millisecond * 150)
+	defer ticker.Stop()
+	for {
+		containers, err := c.Container.List(&client.ListOpts{
+			Filters: map[string]interface{}{
+				"revisionId":   revisionId,
+				"removed_null": nil,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(containers.Data) > 0 {
+			container := &containers.Data[0]
+			return container, waitContainer(ctx, c, container)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrTimeout
+		case <-ticker.C:
+		}
+	}
+}
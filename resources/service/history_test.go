@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerHistory(t *testing.T) {
+	var history []ContainerRevision
+	for i := 0; i < maxContainerHistory+2; i++ {
+		history = append(history, ContainerRevision{
+			RevisionId: "1rev" + string(rune('a'+i)),
+			Image:      "nginx",
+		})
+	}
+
+	config := &client.ContainerConfig{
+		Metadata: map[string]interface{}{
+			"foo": "bar",
+		},
+	}
+	setContainerHistory(config, history)
+	assert.Equal(t, "bar", config.Metadata["foo"])
+
+	// Metadata read back from the API is plain JSON
+	container := &client.Container{
+		Metadata: map[string]interface{}{
+			containerHistoryKey: []interface{}{},
+		},
+	}
+	for _, revision := range config.Metadata[containerHistoryKey].([]ContainerRevision) {
+		container.Metadata[containerHistoryKey] = append(container.Metadata[containerHistoryKey].([]interface{}), map[string]interface{}{
+			"revisionId": revision.RevisionId,
+			"image":      revision.Image,
+		})
+	}
+
+	parsed, err := ContainerHistory(container)
+	assert.NoError(t, err)
+	assert.Equal(t, history[2:], parsed)
+
+	parsed, err = ContainerHistory(&client.Container{})
+	assert.NoError(t, err)
+	assert.Empty(t, parsed)
+}
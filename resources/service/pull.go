@@ -2,14 +2,18 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"context"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/metrics"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 )
 
-func pullImage(ctx context.Context, c *client.RancherClient, image string, labels map[string]string, pullCached bool) error {
+func pullImage(ctx context.Context, c *client.RancherClient, p *progress.Progress, image string, labels map[string]string, pullCached bool) error {
 	taskOpts := &client.PullTask{
 		Mode:   "all",
 		Labels: labels,
@@ -20,6 +24,11 @@ func pullImage(ctx context.Context, c *client.RancherClient, image string, label
 		taskOpts.Mode = "cached"
 	}
 
+	start := time.Now()
+	defer func() {
+		metrics.ImagePullDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	task, err := c.PullTask.Create(taskOpts)
 	if err != nil {
 		return err
@@ -27,12 +36,21 @@ func pullImage(ctx context.Context, c *client.RancherClient, image string, label
 
 	printed := map[string]string{}
 	lastMessage := ""
+	defer p.Clear("image", image)
 	WaitFor(ctx, c, &task.Resource, task, func() string {
 		if task.TransitioningMessage != "" && task.TransitioningMessage != "In Progress" && task.TransitioningMessage != lastMessage {
-			printStatus(task.Image, printed, task.Status)
+			printStatus(logging.FromContext(ctx), task.Image, printed, task.Status)
 			lastMessage = task.TransitioningMessage
 		}
 
+		pulling := 0
+		for _, status := range task.Status {
+			if status != "Done" {
+				pulling++
+			}
+		}
+		p.Update("image", image, fmt.Sprintf("Pulling image %s on %d hosts", image, pulling))
+
 		return task.Transitioning
 	})
 
@@ -40,10 +58,10 @@ func pullImage(ctx context.Context, c *client.RancherClient, image string, label
 		return errors.New(task.TransitioningMessage)
 	}
 
-	if !printStatus(task.Image, printed, task.Status) {
+	if !printStatus(logging.FromContext(ctx), task.Image, printed, task.Status) {
 		return errors.New("Pull failed on one of the hosts")
 	}
 
-	log.Infof("Finished pulling %s", task.Image)
+	logging.FromContext(ctx).Infof("Finished pulling %s", task.Image)
 	return nil
 }
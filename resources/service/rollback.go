@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+	"golang.org/x/net/context"
+)
+
+const defaultHealthTimeout = 5 * time.Minute
+
+// Rollbacker is implemented by wrappers that can undo their last upgrade
+type Rollbacker interface {
+	Rollback(ctx context.Context) error
+}
+
+// UpgradeFailedError is returned when a service with rollback_on_failure
+// fails to become healthy after its upgrade
+type UpgradeFailedError struct {
+	Service string
+	Err     error
+}
+
+func (e *UpgradeFailedError) Error() string {
+	return fmt.Sprintf("Upgrade of service %s failed: %v", e.Service, e.Err)
+}
+
+// HealthTimeout returns how long a service has to become healthy after its
+// upgrade, or zero if the health of the upgrade isn't checked
+func HealthTimeout(strategy config.UpgradeStrategy) time.Duration {
+	switch {
+	case strategy.RollbackOnFailure:
+	case strategy.Strategy == config.CanaryStrategy:
+	case strategy.Strategy == config.BlueGreenStrategy:
+	default:
+		return 0
+	}
+	if strategy.HealthTimeoutMillis > 0 {
+		return time.Duration(strategy.HealthTimeoutMillis) * time.Millisecond
+	}
+	return defaultHealthTimeout
+}
+
+// UpgradeTimeout returns how long the upgrade of a service may take beyond
+// the upgrade itself
+func UpgradeTimeout(strategy config.UpgradeStrategy) time.Duration {
+	timeout := HealthTimeout(strategy)
+	if strategy.Strategy == config.CanaryStrategy && !strategy.CanaryConfirm {
+		timeout += time.Duration(strategy.CanaryPauseMillis) * time.Millisecond
+	}
+	return timeout
+}
+
+// waitHealthy waits for an upgraded service to become healthy, failing as
+// soon as it goes into error
+func waitHealthy(ctx context.Context, c *client.RancherClient, service *client.Service, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Millisecond * 150)
+	defer ticker.Stop()
+	for {
+		if service.State == "error" || service.Transitioning == "error" {
+			return fmt.Errorf("service went into error: %s", service.TransitioningMessage)
+		}
+		if service.HealthState == "healthy" || service.HealthState == "started-once" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service did not become healthy within %v, health state is %s", timeout, service.HealthState)
+		case <-ticker.C:
+		}
+
+		if err := c.Reload(&service.Resource, service); err != nil {
+			return err
+		}
+	}
+}
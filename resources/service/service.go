@@ -1,10 +1,13 @@
 package service
 
 import (
+	"fmt"
+
 	"golang.org/x/net/context"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api/types/container"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
 	"github.com/rancher/rancher-compose-executor/utils"
@@ -64,6 +67,7 @@ func NewSidekick(name string, p *project.Project) *Service {
 }
 
 func (s *Service) Create(ctx context.Context, options options.Options) error {
+	ctx = logging.WithField(ctx, logging.Service, s.name)
 	exists, err := s.wrapper.Exists()
 	if err != nil {
 		return err
@@ -77,10 +81,32 @@ func (s *Service) Create(ctx context.Context, options options.Options) error {
 }
 
 func (s *Service) Up(ctx context.Context, options options.Options) error {
+	ctx = logging.WithField(ctx, logging.Service, s.name)
 	return s.wrapper.Up(ctx, options)
 }
 
+// Action applies a stack wide action, skipping services that don't exist
+func (s *Service) Action(ctx context.Context, action project.Action, options options.Options) error {
+	ctx = logging.WithField(ctx, logging.Service, s.name)
+	exists, err := s.wrapper.Exists()
+	if err != nil || !exists {
+		return err
+	}
+	return s.wrapper.Action(ctx, action, options)
+}
+
+// Rollback undoes the last upgrade of the service
+func (s *Service) Rollback(ctx context.Context) error {
+	ctx = logging.WithField(ctx, logging.Service, s.name)
+	rollbacker, ok := s.wrapper.(Rollbacker)
+	if !ok {
+		return fmt.Errorf("Service %s can not be rolled back", s.name)
+	}
+	return rollbacker.Rollback(ctx)
+}
+
 func (s *Service) Pull(ctx context.Context, options options.Pull) (err error) {
+	ctx = logging.WithField(ctx, logging.Service, s.name)
 	image := s.wrapper.Image()
 	if image == "" {
 		return
@@ -88,10 +114,10 @@ func (s *Service) Pull(ctx context.Context, options options.Pull) (err error) {
 
 	labels := s.wrapper.Labels()
 
-	return pullImage(ctx, s.project.Client, image, utils.ToMapString(labels), options.Cached)
+	return pullImage(ctx, s.project.Client, s.project.Progress, image, utils.ToMapString(labels), options.Cached)
 }
 
-func printStatus(image string, printed map[string]string, current map[string]string) bool {
+func printStatus(logger *logrus.Entry, image string, printed map[string]string, current map[string]string) bool {
 	good := true
 	for host, status := range current {
 		v := printed[host]
@@ -100,13 +126,13 @@ func printStatus(image string, printed map[string]string, current map[string]str
 		}
 
 		if v == "" {
-			logrus.Infof("Checking for %s on %s...", image, host)
+			logger.Infof("Checking for %s on %s...", image, host)
 			v = "start"
 		} else if printed[host] == "start" && status == "Done" {
-			logrus.Infof("Finished %s on %s", image, host)
+			logger.Infof("Finished %s on %s", image, host)
 			v = "done"
 		} else if printed[host] == "start" && status != "Pulling" && status != v {
-			logrus.Infof("Checking for %s on %s: %s", image, host, status)
+			logger.Infof("Checking for %s on %s: %s", image, host, status)
 			v = status
 		}
 		printed[host] = v
@@ -3,11 +3,13 @@ package service
 import (
 	"fmt"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
 	"github.com/rancher/rancher-compose-executor/convert"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 	"github.com/rancher/rancher-compose-executor/utils"
 	"golang.org/x/net/context"
 )
@@ -16,6 +18,8 @@ const (
 	rollback      = "rollback"
 	finishupgrade = "finishupgrade"
 	activate      = "activate"
+	deactivate    = "deactivate"
+	restart       = "restart"
 )
 
 type ServiceWrapper struct {
@@ -29,12 +33,34 @@ func (s *ServiceWrapper) Exists() (bool, error) {
 }
 
 func (s *ServiceWrapper) Create(ctx context.Context, options options.Options) error {
-	service, err := convert.Service(s.project, s.name)
+	service, err := convert.Service(ctx, s.project, s.name)
 	if err != nil {
 		return err
 	}
 
-	logrus.Debugf("Creating service %s", s.name)
+	if s.project.Config.Services[s.name].UpgradeStrategy.Strategy == config.BlueGreenStrategy {
+		green, err := s.project.ServerResourceLookup.Service(s.name + greenSuffix)
+		if err != nil {
+			return err
+		}
+		if green != nil {
+			// Blue was removed before a previous upgrade renamed green
+			logging.FromContext(ctx).Infof("Finishing the switch of service %s to %s left over from a previous blue/green upgrade", s.name, green.Name)
+			renamed, err := s.finishBlueGreen(ctx, nil, green)
+			if err != nil {
+				return err
+			}
+			return s.upgrade(ctx, renamed, options)
+		}
+
+		hash, err := configHash(service)
+		if err != nil {
+			return err
+		}
+		service.Metadata = withMetadata(service.Metadata, convert.ConfigHashKey, hash)
+	}
+
+	logging.FromContext(ctx).Debugf("Creating service %s", s.name)
 	service.CreateOnly = true
 	service.CompleteUpdate = true
 	if service.LaunchConfig != nil {
@@ -43,11 +69,16 @@ func (s *ServiceWrapper) Create(ctx context.Context, options options.Options) er
 	for i := range service.SecondaryLaunchConfigs {
 		service.SecondaryLaunchConfigs[i].CompleteUpdate = true
 	}
+	s.project.Progress.Update("service", s.name, fmt.Sprintf("Creating service %s", s.name))
 	service, err = s.project.Client.Service.Create(service)
 	if err != nil {
 		return err
 	}
-	return wait(ctx, s.project.Client, service)
+	if err := s.wait(ctx, service, "Creating"); err != nil {
+		return err
+	}
+	s.project.Progress.Done("service", s.name, progress.Created)
+	return nil
 }
 
 func (s *ServiceWrapper) Image() string {
@@ -63,11 +94,15 @@ func (s *ServiceWrapper) upgrade(ctx context.Context, service *client.Service, o
 		return nil
 	}
 
-	updates, err := convert.Service(s.project, s.name)
+	updates, err := convert.Service(ctx, s.project, s.name)
 	if err != nil {
 		return err
 	}
 
+	if s.project.Config.Services[s.name].RetainScale {
+		convert.RetainScale(service, updates)
+	}
+
 	if options.ForceRecreate {
 		if utils.IsSelected(options.Services, s.name) && updates.LaunchConfig != nil {
 			updates.LaunchConfig.ForceUpgrade = true
@@ -79,29 +114,103 @@ func (s *ServiceWrapper) upgrade(ctx context.Context, service *client.Service, o
 		}
 	}
 
+	switch strategy := s.project.Config.Services[s.name].UpgradeStrategy; strategy.Strategy {
+	case "", config.InServiceStrategy:
+	case config.CanaryStrategy:
+		return s.upgradeCanary(ctx, service, updates, strategy)
+	case config.BlueGreenStrategy:
+		return s.upgradeBlueGreen(ctx, service, updates, strategy)
+	default:
+		return fmt.Errorf("Service %s: unknown upgrade strategy %s", s.name, strategy.Strategy)
+	}
+
+	previousRevisionId := service.RevisionId
+	s.project.Progress.Update("service", s.name, fmt.Sprintf("Upgrading service %s", s.name))
 	if err = utils.RetryOnError(10, updateServiceWrapper(s.project.Client, service, updates)); err != nil {
 		return err
 	}
 
-	return wait(ctx, s.project.Client, service)
+	if service.RevisionId == previousRevisionId {
+		if err := s.wait(ctx, service, "Upgrading"); err != nil {
+			return err
+		}
+		s.project.Progress.Done("service", s.name, progress.Unchanged)
+		return nil
+	}
+
+	s.project.RecordUpgrade(s.name)
+
+	err = s.wait(ctx, service, "Upgrading")
+	if timeout := HealthTimeout(s.project.Config.Services[s.name].UpgradeStrategy); timeout > 0 {
+		if err == nil {
+			err = waitHealthy(ctx, s.project.Client, service, timeout)
+		}
+		if err != nil {
+			return &UpgradeFailedError{
+				Service: s.name,
+				Err:     err,
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if service.State == "upgraded" && s.project.Config.ManualFinishUpgrade(s.name) {
+		logging.FromContext(ctx).Infof("Service %s is upgraded, leaving it for the upgrade to be finished or rolled back", s.name)
+		s.project.Progress.Done("service", s.name, progress.Pending)
+		return nil
+	}
+
+	s.project.Progress.Done("service", s.name, progress.Upgraded)
+	return nil
 }
 
-func updateServiceWrapper(client *client.RancherClient, service *client.Service, updates *client.Service) func() error {
+func updateServiceWrapper(client *client.RancherClient, service *client.Service, updates interface{}) func() error {
 	return func() error {
-		_, err := client.Service.Update(service, updates)
+		updated, err := client.Service.Update(service, updates)
 		if err != nil {
 			return err
 		}
+		*service = *updated
 		return nil
 	}
 }
 
+// wait waits for the service to settle, reporting its scale as it goes
+func (s *ServiceWrapper) wait(ctx context.Context, service *client.Service, action string) error {
+	return WaitFor(ctx, s.project.Client, &service.Resource, service, func() string {
+		message := fmt.Sprintf("%s service %s", action, s.name)
+		if service.Scale > 0 {
+			message = fmt.Sprintf("%s (%d/%d)", message, service.CurrentScale, service.Scale)
+		}
+		s.project.Progress.Update("service", s.name, message)
+		return service.Transitioning
+	})
+}
+
+// Rollback rolls the service back to the revision it was upgraded from
+func (s *ServiceWrapper) Rollback(ctx context.Context) error {
+	service, err := s.project.ServerResourceLookup.Service(s.name)
+	if err != nil {
+		return err
+	}
+	if service == nil {
+		return fmt.Errorf("Failed to find service %s", s.name)
+	}
+	return s.rollback(ctx, service)
+}
+
 func (s *ServiceWrapper) rollback(ctx context.Context, service *client.Service) error {
 	if err := utils.RetryOnError(10, ActionWrapper(s.project.Client, service, rollback)); err != nil {
 		return err
 	}
 
-	return wait(ctx, s.project.Client, service)
+	if err := s.wait(ctx, service, "Rolling back"); err != nil {
+		return err
+	}
+	s.project.Progress.Done("service", s.name, progress.RolledBack)
+	return nil
 }
 
 func (s *ServiceWrapper) Up(ctx context.Context, options options.Options) error {
@@ -117,11 +226,26 @@ func (s *ServiceWrapper) Up(ctx context.Context, options options.Options) error
 		return s.rollback(ctx, service)
 	}
 
+	if pausedCanary(service) {
+		// A paused canary is only confirmed by finishing the upgrade
+		if !options.FinishUpgrade {
+			return fmt.Errorf("Service %s has a canary awaiting confirmation, finish or roll back the upgrade before updating the stack", s.name)
+		}
+		if err := s.resumeCanary(ctx, service); err != nil {
+			return err
+		}
+	} else if service.State == "paused" {
+		return fmt.Errorf("Service %s has a paused upgrade, resume or roll back the upgrade before updating the stack", s.name)
+	}
+
 	if service.State == "upgraded" {
+		if s.project.Config.ManualFinishUpgrade(s.name) && !options.FinishUpgrade {
+			return fmt.Errorf("Service %s has an upgrade awaiting manual confirmation, finish or roll back the upgrade before updating the stack", s.name)
+		}
 		if err := utils.RetryOnError(10, ActionWrapper(s.project.Client, service, finishupgrade)); err != nil {
 			return err
 		}
-		if err = wait(ctx, s.project.Client, service); err != nil {
+		if err = s.wait(ctx, service, "Finishing upgrade of"); err != nil {
 			return err
 		}
 	}
@@ -130,7 +254,7 @@ func (s *ServiceWrapper) Up(ctx context.Context, options options.Options) error
 		if err := utils.RetryOnError(10, ActionWrapper(s.project.Client, service, activate)); err != nil {
 			return err
 		}
-		if err = wait(ctx, s.project.Client, service); err != nil {
+		if err = s.wait(ctx, service, "Activating"); err != nil {
 			return err
 		}
 	}
@@ -140,17 +264,27 @@ func (s *ServiceWrapper) Up(ctx context.Context, options options.Options) error
 
 func ActionWrapper(c *client.RancherClient, service *client.Service, action string) func() error {
 	return func() error {
+		var result *client.Service
+		var err error
 		switch action {
 		case rollback:
-			_, err := c.Service.ActionRollback(service, nil)
-			return err
+			result, err = c.Service.ActionRollback(service, nil)
 		case finishupgrade:
-			_, err := c.Service.ActionFinishupgrade(service)
-			return err
+			result, err = c.Service.ActionFinishupgrade(service)
 		case activate:
-			_, err := c.Service.ActionActivate(service)
+			result, err = c.Service.ActionActivate(service)
+		case deactivate:
+			result, err = c.Service.ActionDeactivate(service)
+		case restart:
+			result, err = c.Service.ActionRestart(service)
+		}
+		if err != nil {
 			return err
 		}
+		// Keep the service up to date so waiting on it sees the transition
+		if result != nil {
+			*service = *result
+		}
 		return nil
 	}
 }
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/convert"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/project/progress"
+	"github.com/rancher/rancher-compose-executor/utils"
+	"golang.org/x/net/context"
+)
+
+const (
+	greenSuffix = "-green"
+
+	defaultBatchSize      = 1
+	defaultIntervalMillis = 2000
+)
+
+// upgradeCanary upgrades a few instances of the service first, pausing the
+// upgrade once they are healthy
+func (s *ServiceWrapper) upgradeCanary(ctx context.Context, service *client.Service, updates *client.Service, strategy config.UpgradeStrategy) error {
+	canaries := strategy.CanaryInstances
+	if canaries <= 0 {
+		canaries = 1
+	}
+	healthTimeout := HealthTimeout(strategy)
+
+	// Upgrade the canaries in the first batch, and hold the next batch back
+	// long enough for the canaries to be checked and the upgrade paused
+	updates.BatchSize = canaries
+	updates.IntervalMillis = int64((healthTimeout + time.Minute) / time.Millisecond)
+	updates.Metadata = withMetadata(updates.Metadata, convert.CanaryKey, "true")
+
+	previousRevisionId := service.RevisionId
+	s.project.Progress.Update("service", s.name, fmt.Sprintf("Upgrading canary of service %s", s.name))
+	if err := utils.RetryOnError(10, updateServiceWrapper(s.project.Client, service, updates)); err != nil {
+		return err
+	}
+
+	if service.RevisionId == previousRevisionId {
+		if err := s.restoreBatches(service, strategy); err != nil {
+			return err
+		}
+		if err := s.wait(ctx, service, "Upgrading"); err != nil {
+			return err
+		}
+		s.project.Progress.Done("service", s.name, progress.Unchanged)
+		return nil
+	}
+
+	s.project.RecordUpgrade(s.name)
+
+	if err := s.waitCanary(ctx, service, canaries, healthTimeout); err != nil {
+		if strategy.RollbackOnFailure {
+			return &UpgradeFailedError{
+				Service: s.name,
+				Err:     err,
+			}
+		}
+		logging.FromContext(ctx).Errorf("Canary of service %s failed, rolling back: %v", s.name, err)
+		if rollbackErr := s.rollback(ctx, service); rollbackErr != nil {
+			return fmt.Errorf("Canary of service %s failed: %v, rolling back failed: %v", s.name, err, rollbackErr)
+		}
+		return fmt.Errorf("Canary of service %s failed and was rolled back: %v", s.name, err)
+	}
+
+	paused, err := s.project.Client.Service.ActionPause(service)
+	if err != nil {
+		return err
+	}
+	*service = *paused
+	if err := s.wait(ctx, service, "Pausing"); err != nil {
+		return err
+	}
+
+	if strategy.CanaryConfirm {
+		logging.FromContext(ctx).Infof("Canary of service %s is healthy, finishing the upgrade of the stack continues it", s.name)
+		s.project.Progress.Done("service", s.name, progress.Upgraded)
+		return nil
+	}
+
+	if strategy.CanaryPauseMillis > 0 {
+		s.project.Progress.Update("service", s.name, fmt.Sprintf("Running canary of service %s", s.name))
+		select {
+		case <-ctx.Done():
+			return ErrTimeout
+		case <-time.After(time.Duration(strategy.CanaryPauseMillis) * time.Millisecond):
+		}
+	}
+
+	if err := s.resumeCanary(ctx, service); err != nil {
+		return err
+	}
+	s.project.Progress.Done("service", s.name, progress.Upgraded)
+	return nil
+}
+
+// resumeCanary continues a paused canary upgrade with the configured batches
+func (s *ServiceWrapper) resumeCanary(ctx context.Context, service *client.Service) error {
+	s.project.Progress.Update("service", s.name, fmt.Sprintf("Continuing upgrade of service %s", s.name))
+	if err := s.restoreBatches(service, s.project.Config.Services[s.name].UpgradeStrategy); err != nil {
+		return err
+	}
+	if err := utils.RetryOnError(10, ActionWrapper(s.project.Client, service, activate)); err != nil {
+		return err
+	}
+	return s.wait(ctx, service, "Continuing upgrade of")
+}
+
+// pausedCanary tells if the service was paused by a canary upgrade, rather
+// than by hand
+func pausedCanary(service *client.Service) bool {
+	_, ok := service.Metadata[convert.CanaryKey]
+	return service.State == "paused" && ok
+}
+
+// restoreBatches puts back the batches of the upgrade strategy and drops the
+// canary marker
+func (s *ServiceWrapper) restoreBatches(service *client.Service, strategy config.UpgradeStrategy) error {
+	batchSize := strategy.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	intervalMillis := strategy.IntervalMillis
+	if intervalMillis <= 0 {
+		intervalMillis = defaultIntervalMillis
+	}
+	metadata := map[string]interface{}{}
+	for k, v := range service.Metadata {
+		if k != convert.CanaryKey {
+			metadata[k] = v
+		}
+	}
+	return utils.RetryOnError(10, updateServiceWrapper(s.project.Client, service, map[string]interface{}{
+		"batchSize":      batchSize,
+		"intervalMillis": intervalMillis,
+		"metadata":       metadata,
+	}))
+}
+
+// waitCanary waits for the canaries, the first instances of the new
+// revision, to be running and healthy
+func (s *ServiceWrapper) waitCanary(ctx context.Context, service *client.Service, canaries int64, timeout time.Duration) error {
+	if service.Scale > 0 && canaries > service.Scale {
+		canaries = service.Scale
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Millisecond * 150)
+	defer ticker.Stop()
+	for {
+		containers, err := s.project.Client.Container.List(&client.ListOpts{
+			Filters: map[string]interface{}{
+				"serviceId":    service.Id,
+				"revisionId":   service.RevisionId,
+				"removed_null": nil,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		var healthy int64
+		for _, container := range containers.Data {
+			if container.State == "error" {
+				return fmt.Errorf("canary %s went into error: %s", container.Name, container.TransitioningMessage)
+			}
+			if container.State == "running" && (container.HealthState == "" || container.HealthState == "healthy") {
+				healthy++
+			}
+		}
+		s.project.Progress.Update("service", s.name, fmt.Sprintf("Upgrading canary of service %s (%d/%d healthy)", s.name, healthy, canaries))
+		if healthy >= canaries {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d of %d canaries became healthy within %v", healthy, canaries, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// upgradeBlueGreen deploys the new launch configs as a parallel service and
+// switches load balancers and service links over to it once it is healthy.
+// The new service has no previous revision, so it isn't recorded for
+// rollback.
+func (s *ServiceWrapper) upgradeBlueGreen(ctx context.Context, service *client.Service, updates *client.Service, strategy config.UpgradeStrategy) error {
+	hash, err := configHash(updates)
+	if err != nil {
+		return err
+	}
+
+	if fmt.Sprint(service.Metadata[convert.ConfigHashKey]) == hash {
+		// Launch configs are unchanged, apply scale and policy in place
+		updates.Metadata = withMetadata(updates.Metadata, convert.ConfigHashKey, hash)
+		if err := utils.RetryOnError(10, updateServiceWrapper(s.project.Client, service, updates)); err != nil {
+			return err
+		}
+		if err := s.wait(ctx, service, "Upgrading"); err != nil {
+			return err
+		}
+		s.project.Progress.Done("service", s.name, progress.Unchanged)
+		return nil
+	}
+
+	greenName := s.name + greenSuffix
+	leftover, err := s.project.ServerResourceLookup.Service(greenName)
+	if err != nil {
+		return err
+	}
+	if leftover != nil {
+		serving, err := s.serving(leftover)
+		if err != nil {
+			return err
+		}
+		if serving {
+			// Traffic was switched before a previous upgrade was cut short
+			logging.FromContext(ctx).Infof("Finishing the switch of service %s to %s left over from a previous blue/green upgrade", s.name, greenName)
+			renamed, err := s.finishBlueGreen(ctx, service, leftover)
+			if err != nil {
+				return err
+			}
+			return s.upgradeBlueGreen(ctx, renamed, updates, strategy)
+		}
+		logging.FromContext(ctx).Infof("Removing service %s left over from a previous blue/green upgrade", greenName)
+		if err := s.project.Client.Service.Delete(leftover); err != nil {
+			return err
+		}
+	}
+
+	green := *updates
+	green.Name = greenName
+	green.Metadata = withMetadata(updates.Metadata, convert.ConfigHashKey, hash)
+	if service.Scale > green.Scale && s.project.Config.Services[s.name].RetainScale {
+		green.Scale = service.Scale
+	}
+
+	s.project.Progress.Update("service", s.name, fmt.Sprintf("Creating green deployment of service %s", s.name))
+	created, err := s.project.Client.Service.Create(&green)
+	if err != nil {
+		return err
+	}
+
+	err = s.wait(ctx, created, "Creating green deployment of")
+	if err == nil {
+		err = waitHealthy(ctx, s.project.Client, created, HealthTimeout(strategy))
+	}
+	if err != nil {
+		// Blue keeps serving, drop green
+		if deleteErr := s.project.Client.Service.Delete(created); deleteErr != nil {
+			logging.FromContext(ctx).Errorf("Failed to remove service %s: %v", greenName, deleteErr)
+		}
+		if strategy.RollbackOnFailure {
+			return &UpgradeFailedError{
+				Service: s.name,
+				Err:     err,
+			}
+		}
+		return fmt.Errorf("Green deployment of service %s failed: %v", s.name, err)
+	}
+
+	s.project.Progress.Update("service", s.name, fmt.Sprintf("Switching traffic of service %s", s.name))
+	if err := s.retarget(ctx, service.Id, service.Name, created.Id, created.Name); err != nil {
+		return err
+	}
+
+	if _, err := s.finishBlueGreen(ctx, service, created); err != nil {
+		return err
+	}
+
+	s.project.Progress.Done("service", s.name, progress.Upgraded)
+	return nil
+}
+
+// finishBlueGreen removes blue, if any, and gives green the name of the
+// service once traffic is switched to it
+func (s *ServiceWrapper) finishBlueGreen(ctx context.Context, blue, green *client.Service) (*client.Service, error) {
+	if blue != nil {
+		if err := s.project.Client.Service.Delete(blue); err != nil {
+			return nil, err
+		}
+	}
+
+	renamed, err := s.project.Client.Service.Update(green, map[string]interface{}{
+		"name": s.name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.retarget(ctx, green.Id, green.Name, renamed.Id, renamed.Name); err != nil {
+		return nil, err
+	}
+	return renamed, nil
+}
+
+// serving tells if a load balancer port rule or service link targets the
+// service
+func (s *ServiceWrapper) serving(service *client.Service) (bool, error) {
+	serving := false
+	err := s.eachService(func(candidate *client.Service) error {
+		if candidate.LbConfig != nil {
+			for _, rule := range candidate.LbConfig.PortRules {
+				if rule.ServiceId == service.Id {
+					serving = true
+				}
+			}
+		}
+		if _, ok := relink(candidate.ServiceLinks, s.linkName(candidate, service.Name), ""); ok {
+			serving = true
+		}
+		return nil
+	})
+	return serving, err
+}
+
+// retarget points the load balancer port rules and service links targeting
+// one service to another
+func (s *ServiceWrapper) retarget(ctx context.Context, fromId, fromName, toId, toName string) error {
+	return s.eachService(func(candidate *client.Service) error {
+		updates := map[string]interface{}{}
+
+		if candidate.LbConfig != nil && fromId != toId {
+			switched := false
+			for j, rule := range candidate.LbConfig.PortRules {
+				if rule.ServiceId == fromId {
+					candidate.LbConfig.PortRules[j].ServiceId = toId
+					switched = true
+				}
+			}
+			if switched {
+				updates["lbConfig"] = candidate.LbConfig
+			}
+		}
+
+		if links, ok := relink(candidate.ServiceLinks, s.linkName(candidate, fromName), s.linkName(candidate, toName)); ok {
+			updates["serviceLinks"] = links
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+		logging.FromContext(ctx).Infof("Switching %s from %s to %s", candidate.Name, fromName, toName)
+		_, err := s.project.Client.Service.Update(candidate, updates)
+		return err
+	})
+}
+
+// eachService calls f with the services of the environment the stack is in
+func (s *ServiceWrapper) eachService(f func(candidate *client.Service) error) error {
+	filters := map[string]interface{}{
+		"removed_null": nil,
+	}
+	if s.project.Stack.AccountId != "" {
+		filters["accountId"] = s.project.Stack.AccountId
+	}
+
+	services, err := s.project.Client.Service.List(&client.ListOpts{
+		Filters: filters,
+	})
+	for services != nil && err == nil {
+		for i := range services.Data {
+			if err := f(&services.Data[i]); err != nil {
+				return err
+			}
+		}
+		services, err = services.Next()
+	}
+	return err
+}
+
+// linkName is the name a service links to a service of the stack by,
+// qualified with the stack name from other stacks
+func (s *ServiceWrapper) linkName(candidate *client.Service, name string) string {
+	if candidate.StackId == s.project.Stack.Id {
+		return name
+	}
+	return s.project.Stack.Name + "/" + name
+}
+
+func relink(links []client.Link, from, to string) ([]client.Link, bool) {
+	changed := false
+	result := make([]client.Link, len(links))
+	for i, link := range links {
+		if link.Name == from {
+			link.Name = to
+			changed = true
+		}
+		result[i] = link
+	}
+	return result, changed
+}
+
+// configHash identifies the launch configs of a service
+func configHash(service *client.Service) (string, error) {
+	bytes, err := json.Marshal([]interface{}{
+		service.LaunchConfig,
+		service.SecondaryLaunchConfigs,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func withMetadata(metadata map[string]interface{}, key, value string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, v := range metadata {
+		result[k] = v
+	}
+	result[key] = value
+	return result
+}
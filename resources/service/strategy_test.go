@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeTimeout(t *testing.T) {
+	assert.Equal(t, time.Duration(0), UpgradeTimeout(config.UpgradeStrategy{}))
+	assert.Equal(t, defaultHealthTimeout, UpgradeTimeout(config.UpgradeStrategy{
+		Strategy: config.BlueGreenStrategy,
+	}))
+	assert.Equal(t, 3*time.Second, UpgradeTimeout(config.UpgradeStrategy{
+		RollbackOnFailure:   true,
+		HealthTimeoutMillis: 3000,
+	}))
+	assert.Equal(t, 5*time.Second, UpgradeTimeout(config.UpgradeStrategy{
+		Strategy:            config.CanaryStrategy,
+		HealthTimeoutMillis: 3000,
+		CanaryPauseMillis:   2000,
+	}))
+	assert.Equal(t, 3*time.Second, UpgradeTimeout(config.UpgradeStrategy{
+		Strategy:            config.CanaryStrategy,
+		HealthTimeoutMillis: 3000,
+		CanaryPauseMillis:   2000,
+		CanaryConfirm:       true,
+	}))
+}
+
+func TestRelink(t *testing.T) {
+	links, ok := relink([]client.Link{
+		{Name: "web", Alias: "www"},
+		{Name: "db"},
+	}, "web", "web-green")
+	assert.True(t, ok)
+	assert.Equal(t, []client.Link{
+		{Name: "web-green", Alias: "www"},
+		{Name: "db"},
+	}, links)
+
+	_, ok = relink([]client.Link{{Name: "db"}}, "web", "web-green")
+	assert.False(t, ok)
+}
+
+func TestConfigHash(t *testing.T) {
+	a, err := configHash(&client.Service{
+		Scale:        1,
+		LaunchConfig: &client.LaunchConfig{ImageUuid: "docker:nginx:1"},
+	})
+	assert.NoError(t, err)
+
+	b, _ := configHash(&client.Service{
+		Scale:        3,
+		LaunchConfig: &client.LaunchConfig{ImageUuid: "docker:nginx:1"},
+	})
+	assert.Equal(t, a, b)
+
+	c, _ := configHash(&client.Service{
+		LaunchConfig: &client.LaunchConfig{ImageUuid: "docker:nginx:2"},
+	})
+	assert.NotEqual(t, a, c)
+}
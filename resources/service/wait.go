@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/metrics"
 )
 
 var (
@@ -36,6 +37,7 @@ func WaitFor(ctx context.Context, client *client.RancherClient, resource *client
 			if transitioning() != "yes" {
 				return nil
 			}
+			metrics.WaitPolls.Inc(resource.Type)
 			err := client.Reload(resource, output)
 			if err != nil {
 				return err
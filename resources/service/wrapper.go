@@ -1,6 +1,7 @@
 package service
 
 import (
+	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
 	"golang.org/x/net/context"
 )
@@ -9,6 +10,7 @@ type Wrapper interface {
 	Exists() (bool, error)
 	Create(ctx context.Context, options options.Options) error
 	Up(ctx context.Context, options options.Options) error
+	Action(ctx context.Context, action project.Action, options options.Options) error
 	Image() string
 	Labels() map[string]interface{}
 }
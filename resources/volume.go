@@ -5,11 +5,12 @@ import (
 
 	"golang.org/x/net/context"
 
-	"github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
 	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/logging"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 )
 
 func VolumesCreate(p *project.Project) (project.ResourceSet, error) {
@@ -93,15 +94,21 @@ func (v *Volume) EnsureItExists(ctx context.Context) error {
 	}
 
 	if volumeResource == nil {
-		logrus.Infof("Creating volume template %s", v.name)
-		return v.create(ctx)
+		logging.FromContext(ctx).Infof("Creating volume template %s", v.name)
+		v.project.Progress.Update("volume", v.name, fmt.Sprintf("Creating volume template %s", v.name))
+		if err := v.create(ctx); err != nil {
+			return err
+		}
+		v.project.Progress.Done("volume", v.name, progress.Created)
+		return nil
 	} else {
-		logrus.Infof("Existing volume template found for %s", v.name)
+		logging.FromContext(ctx).Infof("Existing volume template found for %s", v.name)
 	}
 
 	if v.driver != "" && volumeResource.Driver != v.driver {
 		return fmt.Errorf("Volume %q needs to be recreated - driver has changed", v.name)
 	}
+	v.project.Progress.Done("volume", v.name, progress.Unchanged)
 	return nil
 }
 
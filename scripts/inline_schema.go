@@ -20,6 +20,10 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	schemaV3, err := ioutil.ReadFile("./scripts/config_schema_v3.json")
+	if err != nil {
+		panic(err)
+	}
 
 	inlinedFile, err := os.Create("parser/schema.go")
 	if err != nil {
@@ -29,6 +33,7 @@ func main() {
 	err = t.Execute(inlinedFile, map[string]string{
 		"schemaV1": string(schemaV1),
 		"schemaV2": string(schemaV2),
+		"schemaV3": string(schemaV3),
 	})
 
 	if err != nil {
@@ -5,9 +5,16 @@ import (
 	"text/template"
 )
 
+// Funcs are the functions of templates from trusted catalogs
 var Funcs template.FuncMap
 
+// SandboxFuncs are the functions of all other templates, without access to
+// the host and with bounded repetition
+var SandboxFuncs template.FuncMap
+
 func init() {
 	Funcs = sprig.TxtFuncMap()
 	Funcs["splitPreserveQuotes"] = splitPreserveQuotes
+
+	SandboxFuncs = sandbox(Funcs)
 }
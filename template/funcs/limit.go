@@ -0,0 +1,149 @@
+package funcs
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+	// formatSpec matches the flags, width and precision of a printf verb
+	formatSpec = regexp.MustCompile(`%[^a-zA-Z%]*`)
+	number     = regexp.MustCompile(`\d+`)
+)
+
+// builtins replace the functions text/template provides, so that they are
+// bounded like the others
+var builtins = template.FuncMap{
+	"html":     template.HTMLEscaper,
+	"js":       template.JSEscaper,
+	"print":    fmt.Sprint,
+	"printf":   printf,
+	"println":  fmt.Sprintln,
+	"urlquery": template.URLQueryEscaper,
+}
+
+// limit wraps a template function so that it fails rather than take
+// arguments or produce a result larger than MaxStringSize. Every string a
+// template builds comes from a function, so no intermediate value of the
+// template grows past the limit.
+func limit(name string, f interface{}) interface{} {
+	v := reflect.ValueOf(f)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumOut() == 0 || t.NumOut() > 2 {
+		return f
+	}
+	if t.NumOut() == 2 && t.Out(1) != errorType {
+		return f
+	}
+
+	in := make([]reflect.Type, t.NumIn())
+	for i := range in {
+		in[i] = t.In(i)
+	}
+	out := []reflect.Type{t.Out(0), errorType}
+
+	fail := func(err error) []reflect.Value {
+		return []reflect.Value{reflect.Zero(out[0]), reflect.ValueOf(&err).Elem()}
+	}
+
+	return reflect.MakeFunc(reflect.FuncOf(in, out, t.IsVariadic()), func(args []reflect.Value) []reflect.Value {
+		size := 0
+		for _, arg := range args {
+			size += measure(arg, MaxStringSize-size+1)
+		}
+		if size > MaxStringSize {
+			return fail(fmt.Errorf("%s: arguments are larger than %d bytes", name, MaxStringSize))
+		}
+
+		var results []reflect.Value
+		if t.IsVariadic() {
+			results = v.CallSlice(args)
+		} else {
+			results = v.Call(args)
+		}
+		if len(results) == 2 && !results[1].IsNil() {
+			return results
+		}
+		if measure(results[0], MaxStringSize+1) > MaxStringSize {
+			return fail(fmt.Errorf("%s: result is larger than %d bytes", name, MaxStringSize))
+		}
+		return []reflect.Value{results[0], reflect.Zero(errorType)}
+	}).Interface()
+}
+
+// measure returns the size of the strings a value holds, counting every
+// element of lists and maps as at least one. It stops counting past max, so
+// that values sharing their elements many times over are measured quickly.
+func measure(v reflect.Value, max int) int {
+	if max <= 0 || !v.IsValid() {
+		return 0
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len()
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return 0
+		}
+		return measure(v.Elem(), max)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Len()
+		}
+		size := 0
+		for i := 0; i < v.Len() && size <= max; i++ {
+			size += 1 + measure(v.Index(i), max-size)
+		}
+		return size
+	case reflect.Map:
+		size := 0
+		for _, key := range v.MapKeys() {
+			if size > max {
+				break
+			}
+			size += 1 + measure(key, max-size)
+			size += measure(v.MapIndex(key), max-size)
+		}
+		return size
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < v.NumField() && size <= max; i++ {
+			size += measure(v.Field(i), max-size)
+		}
+		return size
+	}
+	return 0
+}
+
+// printf bounds the padding the widths and precisions of the format add,
+// which the size of the arguments doesn't account for
+func printf(format string, args ...interface{}) (string, error) {
+	size := len(format)
+	for _, spec := range formatSpec.FindAllString(format, -1) {
+		for _, n := range number.FindAllString(spec, -1) {
+			value, err := strconv.Atoi(n)
+			if err != nil {
+				return "", fmt.Errorf("printf: invalid format %q", spec)
+			}
+			size += value
+		}
+		// Widths and precisions taken from the arguments
+		for i := 0; i < strings.Count(spec, "*"); i++ {
+			for _, arg := range args {
+				if value, ok := arg.(int); ok && value > 0 {
+					size += value
+				}
+			}
+		}
+	}
+	if err := checkSize("printf", size); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(format, args...), nil
+}
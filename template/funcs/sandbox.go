@@ -0,0 +1,145 @@
+package funcs
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	// MaxItems bounds the lists until and untilStep produce in sandboxed
+	// templates
+	MaxItems = 10000
+	// MaxStringSize bounds the arguments and results of the functions of
+	// sandboxed templates
+	MaxStringSize = 1 << 20
+)
+
+// hostFuncs give access to the environment of the executor, which holds its
+// API credentials, or take long enough to tie the executor up
+var hostFuncs = []string{
+	"env",
+	"expandenv",
+	"genPrivateKey",
+}
+
+func sandbox(funcs template.FuncMap) template.FuncMap {
+	result := template.FuncMap{}
+	for name, f := range funcs {
+		result[name] = f
+	}
+	for _, name := range hostFuncs {
+		delete(result, name)
+	}
+
+	result["repeat"] = repeat
+	result["replace"] = replace
+	result["until"] = until
+	result["untilStep"] = untilStep
+	result["wrap"] = limitWrap(funcs["wrap"].(func(int, string) string))
+	result["wrapWith"] = limitWrapWith(funcs["wrapWith"].(func(int, string, string) string))
+	result["indent"] = indent
+	for _, name := range []string{"randAlphaNum", "randAlpha", "randAscii", "randNumeric"} {
+		result[name] = limitRand(name, funcs[name].(func(int) string))
+	}
+	for name, f := range builtins {
+		result[name] = f
+	}
+
+	for name, f := range result {
+		result[name] = limit(name, f)
+	}
+	return result
+}
+
+func checkSize(name string, size int) error {
+	if size > MaxStringSize || size < 0 {
+		return fmt.Errorf("%s: result is larger than %d bytes", name, MaxStringSize)
+	}
+	return nil
+}
+
+func repeat(count int, s string) (string, error) {
+	if count <= 0 {
+		return "", nil
+	}
+	if len(s) > MaxStringSize/count {
+		return "", fmt.Errorf("repeat: result is larger than %d bytes", MaxStringSize)
+	}
+	return strings.Repeat(s, count), nil
+}
+
+func replace(old, new, src string) (string, error) {
+	count := strings.Count(src, old)
+	if len(new) > len(old) && count > 0 {
+		if err := checkSize("replace", len(src)+count*(len(new)-len(old))); err != nil {
+			return "", err
+		}
+	}
+	return strings.Replace(src, old, new, -1), nil
+}
+
+func limitRand(name string, f func(int) string) func(int) (string, error) {
+	return func(count int) (string, error) {
+		if err := checkSize(name, count); err != nil {
+			return "", err
+		}
+		return f(count), nil
+	}
+}
+
+// limitWrap bounds wrap, which at worst breaks the line after every
+// character
+func limitWrap(f func(int, string) string) func(int, string) (string, error) {
+	return func(length int, s string) (string, error) {
+		if err := checkSize("wrap", 2*len(s)); err != nil {
+			return "", err
+		}
+		return f(length, s), nil
+	}
+}
+
+func limitWrapWith(f func(int, string, string) string) func(int, string, string) (string, error) {
+	return func(length int, sep, s string) (string, error) {
+		if err := checkSize("wrapWith", len(s)*(1+len(sep))); err != nil {
+			return "", err
+		}
+		return f(length, sep, s), nil
+	}
+}
+
+func indent(spaces int, s string) (string, error) {
+	if spaces < 0 {
+		spaces = 0
+	}
+	lines := strings.Count(s, "\n") + 1
+	if spaces > 0 && lines > MaxStringSize/spaces {
+		return "", fmt.Errorf("indent: result is larger than %d bytes", MaxStringSize)
+	}
+	if err := checkSize("indent", len(s)+spaces*lines); err != nil {
+		return "", err
+	}
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1), nil
+}
+
+func until(count int) ([]int, error) {
+	if count > 0 {
+		return untilStep(0, count, 1)
+	}
+	return untilStep(0, count, -1)
+}
+
+func untilStep(start, stop, step int) ([]int, error) {
+	result := []int{}
+	if step == 0 || (stop > start) != (step > 0) {
+		return result, nil
+	}
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		if len(result) == MaxItems {
+			return nil, fmt.Errorf("untilStep: more than %d items", MaxItems)
+		}
+		result = append(result, i)
+	}
+	return result, nil
+}
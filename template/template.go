@@ -2,8 +2,11 @@ package template
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"text/template"
+	"text/template/parse"
+	"time"
 
 	"fmt"
 	"github.com/rancher/go-rancher/catalog"
@@ -11,6 +14,21 @@ import (
 	"github.com/rancher/rancher-compose-executor/template/funcs"
 )
 
+var (
+	// TrustedCatalogs lists the catalogs whose templates may use every
+	// template function, including those reading the environment
+	TrustedCatalogs []string
+	// RenderTimeout bounds the time rendering a template may take
+	RenderTimeout = 10 * time.Second
+	// MaxOutputSize bounds the size of a rendered template
+	MaxOutputSize = 4 << 20
+
+	ErrRenderTimeout = errors.New("Rendering the template took too long")
+)
+
+// budgetFunc is the template function checking the render deadline
+const budgetFunc = "renderBudget"
+
 type ClusterInfo struct {
 	Embedded      string
 	Orchestration string
@@ -23,13 +41,28 @@ func Apply(contents []byte, templateVersion *catalog.TemplateVersion, cluster *c
 		return contents, nil
 	}
 
-	t, err := template.New("template").Funcs(funcs.Funcs).Parse(string(contents))
+	templateFuncs := funcs.SandboxFuncs
+	if IsTrusted(templateVersion) {
+		templateFuncs = funcs.Funcs
+	}
+
+	deadline := time.Now().Add(RenderTimeout)
+	t, err := template.New("template").Funcs(templateFuncs).Funcs(template.FuncMap{
+		budgetFunc: func() (string, error) {
+			if time.Now().After(deadline) {
+				return "", ErrRenderTimeout
+			}
+			return "", nil
+		},
+	}).Parse(string(contents))
 	if err != nil {
 		return nil, err
 	}
+	if err := budget(t); err != nil {
+		return nil, err
+	}
 
-	buf := bytes.Buffer{}
-	t.Execute(&buf, map[string]interface{}{
+	return execute(t, deadline, map[string]interface{}{
 		"Values":  variables,
 		"Release": templateVersion,
 		"Stack":   templateVersion,
@@ -38,5 +71,122 @@ func Apply(contents []byte, templateVersion *catalog.TemplateVersion, cluster *c
 			Orchestration: cluster.Orchestration,
 		},
 	})
-	return buf.Bytes(), nil
+}
+
+// IsTrusted tells whether the template version comes from a trusted
+// catalog. Templates of stacks without a catalog are never trusted.
+func IsTrusted(templateVersion *catalog.TemplateVersion) bool {
+	if templateVersion == nil {
+		return false
+	}
+	id := templateVersion.Id
+	if id == "" {
+		id = templateVersion.TemplateId
+	}
+	catalogName := strings.SplitN(id, ":", 2)[0]
+	for _, trusted := range TrustedCatalogs {
+		if catalogName != "" && catalogName == trusted {
+			return true
+		}
+	}
+	return false
+}
+
+// budget makes every template and range iteration start by checking the
+// render deadline, so that a render past its deadline fails even when it
+// produces no output
+func budget(t *template.Template) error {
+	check, err := template.New("").Funcs(template.FuncMap{
+		budgetFunc: func() string { return "" },
+	}).Parse("{{" + budgetFunc + "}}")
+	if err != nil {
+		return err
+	}
+	node := check.Tree.Root.Nodes[0]
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil && tmpl.Tree.Root != nil {
+			budgetList(tmpl.Tree.Root, node)
+			tmpl.Tree.Root.Nodes = append([]parse.Node{node}, tmpl.Tree.Root.Nodes...)
+		}
+	}
+	return nil
+}
+
+func budgetList(list *parse.ListNode, check parse.Node) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			budgetList(n, check)
+		case *parse.IfNode:
+			budgetList(n.List, check)
+			budgetList(n.ElseList, check)
+		case *parse.WithNode:
+			budgetList(n.List, check)
+			budgetList(n.ElseList, check)
+		case *parse.RangeNode:
+			budgetList(n.List, check)
+			budgetList(n.ElseList, check)
+			if n.List != nil {
+				n.List.Nodes = append([]parse.Node{check}, n.List.Nodes...)
+			}
+		}
+	}
+}
+
+// execute renders the template within the deadline and MaxOutputSize. The
+// render fails at the first template or range iteration past the deadline,
+// and output stops being accepted.
+func execute(t *template.Template, deadline time.Time, data interface{}) ([]byte, error) {
+	w := &limitedWriter{
+		deadline: deadline,
+		max:      MaxOutputSize,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- t.Execute(w, data)
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if w.err != nil {
+			return nil, w.err
+		}
+		if err != nil && time.Now().After(deadline) {
+			return nil, ErrRenderTimeout
+		}
+		if err != nil {
+			return nil, err
+		}
+		return w.buf.Bytes(), nil
+	case <-timer.C:
+		return nil, ErrRenderTimeout
+	}
+}
+
+type limitedWriter struct {
+	buf      bytes.Buffer
+	deadline time.Time
+	max      int
+	err      error
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	switch {
+	case w.err != nil:
+	case time.Now().After(w.deadline):
+		w.err = ErrRenderTimeout
+	case w.buf.Len()+len(p) > w.max:
+		w.err = fmt.Errorf("Rendered template is larger than %d bytes", w.max)
+	default:
+		return w.buf.Write(p)
+	}
+	return 0, w.err
 }
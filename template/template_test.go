@@ -0,0 +1,127 @@
+package template
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/go-rancher/catalog"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplySandbox(t *testing.T) {
+	os.Setenv("TEMPLATE_TEST_SECRET", "s3cr3t")
+	defer os.Unsetenv("TEMPLATE_TEST_SECRET")
+
+	contents := []byte(`key: {{ env "TEMPLATE_TEST_SECRET" }}`)
+	cluster := &client.Cluster{}
+
+	_, err := Apply(contents, nil, cluster, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `function "env" not defined`)
+	}
+
+	_, err = Apply([]byte(`key: {{ "$TEMPLATE_TEST_SECRET" | expandenv }}`), &catalog.TemplateVersion{
+		TemplateId: "community:secret",
+	}, cluster, nil)
+	assert.Error(t, err)
+
+	TrustedCatalogs = []string{"library"}
+	defer func() { TrustedCatalogs = nil }()
+
+	output, err := Apply(contents, &catalog.TemplateVersion{
+		Resource: catalog.Resource{Id: "library:infra*ipsec:3"},
+	}, cluster, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "key: s3cr3t", string(output))
+
+	assert.True(t, IsTrusted(&catalog.TemplateVersion{TemplateId: "library:infra*ipsec"}))
+	assert.False(t, IsTrusted(&catalog.TemplateVersion{TemplateId: "libraryx:infra*ipsec"}))
+	assert.False(t, IsTrusted(nil))
+}
+
+func TestApplyLimits(t *testing.T) {
+	cluster := &client.Cluster{}
+
+	output, err := Apply([]byte(`{{ range until 3 }}x{{ end }}`), nil, cluster, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "xxx", string(output))
+
+	_, err = Apply([]byte(`{{ range until 100000 }}x{{ end }}`), nil, cluster, nil)
+	assert.Error(t, err)
+
+	for _, contents := range []string{
+		`{{ repeat 100000000 "x" }}`,
+		`{{ randAlpha 100000000 }}`,
+		`{{ randNumeric 100000000 }}`,
+		`{{ indent 100000000 "x" }}`,
+		`{{ repeat 1000 "x" | wrapWith 1 (repeat 10000 "x") }}`,
+		`{{ repeat 1000 "x" | replace "x" (repeat 10000 "x") }}`,
+		`{{ genPrivateKey "rsa" }}`,
+	} {
+		_, err = Apply([]byte(contents), nil, cluster, nil)
+		assert.Error(t, err, contents)
+	}
+
+	output, err = Apply([]byte(`{{ "a\nb" | indent 2 }}`), nil, cluster, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "  a\n  b", string(output))
+
+	defer func(size int) { MaxOutputSize = size }(MaxOutputSize)
+	MaxOutputSize = 100
+	_, err = Apply([]byte(strings.Repeat("{{ repeat 10 \"x\" }}", 20)), nil, cluster, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "larger than 100 bytes")
+	}
+
+	defer func(timeout time.Duration) { RenderTimeout = timeout }(RenderTimeout)
+	RenderTimeout = time.Nanosecond
+	_, err = Apply([]byte(`{{ range until 5000 }}{{ range until 5000 }}x{{ end }}{{ end }}`), nil, cluster, nil)
+	assert.Equal(t, ErrRenderTimeout, err)
+}
+
+func TestApplyTimeoutWithoutOutput(t *testing.T) {
+	defer func(timeout time.Duration) { RenderTimeout = timeout }(RenderTimeout)
+	RenderTimeout = 50 * time.Millisecond
+
+	goroutines := runtime.NumGoroutine()
+	_, err := Apply([]byte(`{{ range 1000000000 }}{{ range 1000000000 }}{{ end }}{{ end }}`), nil, &client.Cluster{}, nil)
+	assert.Equal(t, ErrRenderTimeout, err)
+
+	// The render stops rather than running on in the background
+	for i := 0; i < 100 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= goroutines)
+}
+
+func TestApplyIntermediateLimits(t *testing.T) {
+	cluster := &client.Cluster{}
+
+	// Values that are never written out are bounded too
+	contents := `{{ $a := printf "%1000000s" "x" }}` + strings.Repeat(`{{ $a = cat $a $a }}`, 8) + `{{ len $a }}`
+	start := time.Now()
+	_, err := Apply([]byte(contents), nil, cluster, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "larger than")
+	}
+	assert.True(t, time.Since(start) < time.Second)
+
+	for _, contents := range []string{
+		`{{ printf "%1000000s%1000000s" "x" "y" }}`,
+		`{{ printf "%*s" 2000000 "x" }}`,
+		`{{ $a := repeat 1000000 "x" }}{{ tuple $a $a | len }}`,
+		`{{ $a := repeat 1000000 "x" }}{{ $a | b64enc }}`,
+		`{{ $a := repeat 1000000 "<" }}{{ $a | html | len }}`,
+	} {
+		_, err = Apply([]byte(contents), nil, cluster, nil)
+		assert.Error(t, err, contents)
+	}
+
+	output, err := Apply([]byte(`{{ printf "%5s|%d" "x" 2000000 }} {{ "a,b" | upper }}`), nil, cluster, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "    x|2000000 A,B", string(output))
+}
@@ -2,14 +2,25 @@ package testcli
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/runconfig/opts"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/lookup"
 	"github.com/rancher/rancher-compose-executor/project"
 	"github.com/rancher/rancher-compose-executor/project/options"
+	"github.com/rancher/rancher-compose-executor/project/progress"
 	_ "github.com/rancher/rancher-compose-executor/resources"
+	"github.com/rancher/rancher-compose-executor/resources/service"
 	"github.com/urfave/cli"
 )
 
@@ -35,7 +46,249 @@ func up(c *cli.Context) error {
 	return p.Up(context.Background(), options.Options{})
 }
 
+func rollback(c *cli.Context) error {
+	p, err := getProject(c)
+	if err != nil {
+		return err
+	}
+	return p.Up(context.Background(), options.Options{
+		Rollback: true,
+		Services: c.Args(),
+	})
+}
+
+func history(c *cli.Context) error {
+	p, err := getProject(c)
+	if err != nil {
+		return err
+	}
+
+	names := c.Args()
+	if len(names) == 0 {
+		for name := range p.Config.Containers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tREVISION\tIMAGE\tDEPLOYED")
+	for _, name := range names {
+		container, err := p.ServerResourceLookup.Container(name)
+		if err != nil {
+			return err
+		}
+		if container == nil {
+			return fmt.Errorf("Failed to find container %s", name)
+		}
+		revisions, err := service.ContainerHistory(container)
+		if err != nil {
+			return err
+		}
+		for _, revision := range revisions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, revision.RevisionId, revision.Image, revision.Deployed)
+		}
+		fmt.Fprintf(w, "%s\t%s (current)\t%s\t%s\n", name, container.RevisionId, container.Image, container.Created)
+	}
+	return w.Flush()
+}
+
+func rm(c *cli.Context) error {
+	p, err := getProject(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	p.Progress = progress.New("")
+	if err := p.Remove(ctx, options.Options{
+		Services: c.Args(),
+	}); err != nil {
+		return err
+	}
+
+	// Only tear down the rest of the stack when all of it was selected
+	if len(c.Args()) == 0 {
+		if err := p.Delete(ctx); err != nil {
+			return err
+		}
+		logrus.Infof("Removing stack %s", p.Stack.Name)
+		if err := p.Client.Stack.Delete(p.Stack); err != nil {
+			return err
+		}
+	}
+
+	logrus.Info(p.Progress.Summary())
+	return nil
+}
+
+func ps(c *cli.Context) error {
+	p, err := getProject(c)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tIMAGE\tSTATE\tHEALTH\tSCALE")
+
+	services, err := p.Client.Service.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"stackId":      p.Stack.Id,
+			"removed_null": nil,
+		},
+	})
+	for services != nil && err == nil {
+		for _, service := range services.Data {
+			var image string
+			if service.LaunchConfig != nil {
+				image = service.LaunchConfig.Image
+			}
+			fmt.Fprintf(w, "%s\tservice\t%s\t%s\t%s\t%d/%d\n", service.Name, image, service.State, service.HealthState, service.CurrentScale, service.Scale)
+		}
+		services, err = services.Next()
+	}
+	if err != nil {
+		return err
+	}
+
+	containers, err := p.Client.Container.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"stackId":      p.Stack.Id,
+			"removed_null": nil,
+		},
+	})
+	for containers != nil && err == nil {
+		for _, container := range containers.Data {
+			fmt.Fprintf(w, "%s\tcontainer\t%s\t%s\t%s\t\n", container.Name, container.Image, container.State, container.HealthState)
+		}
+		containers, err = containers.Next()
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// scale sets the scale of services given as SERVICE=NUM
+func scale(c *cli.Context) error {
+	if len(c.Args()) == 0 {
+		return errors.New("Specify at least one SERVICE=NUM")
+	}
+
+	scales := map[string]int64{}
+	for _, arg := range c.Args() {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid scale %s, expected SERVICE=NUM", arg)
+		}
+		value, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || value < 0 {
+			return fmt.Errorf("Invalid scale %s, expected SERVICE=NUM", arg)
+		}
+		scales[parts[0]] = value
+	}
+
+	p, err := getProject(c)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range scales {
+		ser, err := p.ServerResourceLookup.Service(name)
+		if err != nil {
+			return err
+		}
+		if ser == nil {
+			return fmt.Errorf("Failed to find service %s", name)
+		}
+
+		logrus.Infof("Setting scale of %s to %d", name, value)
+		ser, err = p.Client.Service.Update(ser, map[string]interface{}{
+			"scale": value,
+		})
+		if err != nil {
+			return err
+		}
+		if err := service.WaitFor(context.Background(), p.Client, &ser.Resource, ser, func() string {
+			return ser.Transitioning
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stop(c *cli.Context) error {
+	return action(c, project.DeactivateAction)
+}
+
+func start(c *cli.Context) error {
+	return action(c, project.ActivateAction)
+}
+
+func restart(c *cli.Context) error {
+	return action(c, project.RestartAction)
+}
+
+func action(c *cli.Context, action project.Action) error {
+	p, err := getProject(c)
+	if err != nil {
+		return err
+	}
+
+	p.Progress = progress.New("")
+	if err := p.Action(context.Background(), action, options.Options{
+		Services: c.Args(),
+	}); err != nil {
+		return err
+	}
+
+	logrus.Info(p.Progress.Summary())
+	return nil
+}
+
 func getProject(c *cli.Context) (*project.Project, error) {
+	files, variables, err := getFiles(c)
+	if err != nil {
+		return nil, err
+	}
+
+	projectName := c.GlobalString("project-name")
+	clusterId := c.GlobalString("cluster-id")
+	if clusterId == "" {
+		return nil, errors.New("Specify the cluster with --cluster-id")
+	}
+
+	rancherClient, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := rancherClient.Cluster.ById(clusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	p := project.NewProject(projectName, rancherClient, cluster)
+	p.ResourceLookup = &lookup.FileResourceLookup{
+		Root: projectDir(c),
+	}
+	return p, p.Load(context.Background(), files, variables)
+}
+
+// projectDir is the directory of the first compose file, which files
+// referenced by the templates are relative to
+func projectDir(c *cli.Context) string {
+	if filenames := c.GlobalStringSlice("file"); len(filenames) > 0 {
+		return path.Dir(filenames[0])
+	}
+	return "."
+}
+
+// getFiles reads the templates of the project and the variables of its env
+// file
+func getFiles(c *cli.Context) (map[string]string, map[string]string, error) {
 	files := map[string]string{}
 
 	composeBytes, err := ioutil.ReadFile(composeFilename)
@@ -59,39 +312,25 @@ func getProject(c *cli.Context) (*project.Project, error) {
 		}
 	}
 
-	var relPath string
-	if len(filenames) > 0 {
-		relPath = path.Dir(filenames[0])
-	}
-
-	rancherFile := c.String("rancher-file")
+	rancherFile := c.GlobalString("rancher-file")
 	if rancherFile == "" {
 		rancherFile = rancherComposeFilename
 	}
-	rancherComposeBytes, err := ioutil.ReadFile(path.Join(relPath, rancherFile))
+	rancherComposeBytes, err := ioutil.ReadFile(path.Join(projectDir(c), rancherFile))
 	if err == nil {
 		files[rancherComposeFilename] = string(rancherComposeBytes)
 	}
 
-	envFile := c.String("env-file")
+	envFile := c.GlobalString("env-file")
 	var variables map[string]string
 	if envFile != "" {
 		variables, err = getVariables(envFile)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	projectName := c.GlobalString("project-name")
-	clusterId := c.GlobalString("cluster-id")
-
-	cluster, err := rancherClient.Cluster.ById(clusterId)
-	if err != nil {
-		return nil, err
-	}
-
-	p := project.NewProject(projectName, rancherClient, cluster)
-	return p, p.Load(files, variables)
+	return files, variables, nil
 }
 
 func getVariables(filename string) (map[string]string, error) {
@@ -0,0 +1,153 @@
+package testcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/config"
+	"github.com/rancher/rancher-compose-executor/convert"
+	"github.com/rancher/rancher-compose-executor/lookup"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/rancher/rancher-compose-executor/utils"
+	"github.com/urfave/cli"
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v2"
+)
+
+// payloads are the API objects the services and containers of the stack
+// are created with
+type payloads struct {
+	Services   map[string]*client.Service   `json:"services,omitempty" yaml:"services,omitempty"`
+	Containers map[string]*client.Container `json:"containers,omitempty" yaml:"containers,omitempty"`
+}
+
+// printConfig prints the merged config of the stack, followed by the
+// payloads generated from it and the server side references they use when
+// asked for. The stack is only looked up, and created if missing, when the
+// references are resolved.
+func printConfig(c *cli.Context) error {
+	format := c.String("format")
+	if format != "yaml" && format != "json" {
+		return fmt.Errorf("Invalid format %s, must be yaml or json", format)
+	}
+
+	getConfigProject := getOfflineProject
+	if c.Bool("resolve") {
+		getConfigProject = getProject
+	}
+	p, err := getConfigProject(c)
+	if err != nil {
+		return err
+	}
+
+	if err := printDocument(format, p.Config, true); err != nil {
+		return err
+	}
+
+	if !c.Bool("payloads") && !c.Bool("references") {
+		return nil
+	}
+
+	references := &lookup.ReferenceLookup{}
+	if c.Bool("resolve") {
+		references.Resolver = p.ServerResourceLookup
+	}
+	p.ServerResourceLookup = references
+
+	generated, err := generatePayloads(p)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("payloads") {
+		if err := printDocument(format, generated, false); err != nil {
+			return err
+		}
+	}
+	if c.Bool("references") {
+		return printDocument(format, references.References(), false)
+	}
+	return nil
+}
+
+// getOfflineProject parses the templates without a Rancher server
+func getOfflineProject(c *cli.Context) (*project.Project, error) {
+	files, variables, err := getFiles(c)
+	if err != nil {
+		return nil, err
+	}
+
+	p := project.NewOfflineProject(c.GlobalString("project-name"), nil)
+	p.ResourceLookup = &lookup.FileResourceLookup{
+		Root: projectDir(c),
+	}
+	return p, p.Parse(context.Background(), files, variables)
+}
+
+func generatePayloads(p *project.Project) (*payloads, error) {
+	result := &payloads{
+		Services:   map[string]*client.Service{},
+		Containers: map[string]*client.Container{},
+	}
+
+	// Sorted so that references are listed in a stable order
+	for _, name := range sortedNames(p.Config.Services) {
+		// Sidekicks are part of the payload of their primary service
+		if len(p.Config.SidekickInfo.SidekickToPrimaries[name]) > 0 {
+			continue
+		}
+		service, err := convert.Service(context.Background(), p, name)
+		if err != nil {
+			return nil, err
+		}
+		result.Services[name] = service
+	}
+
+	for _, name := range sortedNames(p.Config.Containers) {
+		container, err := convert.Container(context.Background(), p, name)
+		if err != nil {
+			return nil, err
+		}
+		result.Containers[name] = container
+	}
+
+	return result, nil
+}
+
+// printDocument prints a YAML document or a JSON value. Compose keys are
+// kept for config, which only has YAML tags, while API objects use their
+// JSON field names.
+func printDocument(format string, value interface{}, yamlKeys bool) error {
+	if format == "yaml" {
+		content, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("---\n%s", content)
+		return nil
+	}
+
+	if yamlKeys {
+		var generic map[string]interface{}
+		if err := utils.Convert(value, &generic); err != nil {
+			return err
+		}
+		value = utils.NestedMapsToMapInterface(generic)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func sortedNames(m map[string]*config.ServiceConfig) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
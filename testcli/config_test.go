@@ -0,0 +1,45 @@
+package testcli
+
+import (
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestGeneratePayloadsOffline(t *testing.T) {
+	p := project.NewOfflineProject("render", nil)
+	if !assert.NoError(t, p.Parse(context.Background(), map[string]string{
+		"compose.yml": `
+version: "2"
+services:
+  web:
+    image: nginx
+    secrets:
+    - password
+  lb:
+    image: rancher/load-balancer-service
+    ports:
+    - 80:80
+    links:
+    - web
+secrets:
+  password:
+    external: true
+`,
+	}, nil)) {
+		return
+	}
+
+	payloads, err := generatePayloads(p)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []client.SecretReference{{
+		SecretId: "${secret:password}",
+		Name:     "password",
+	}}, payloads.Services["web"].LaunchConfig.Secrets)
+	assert.Equal(t, "rancher/lb-service-haproxy", payloads.Services["lb"].LaunchConfig.Image)
+}
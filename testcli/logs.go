@@ -0,0 +1,130 @@
+package testcli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/rancher/rancher-compose-executor/utils"
+	"github.com/urfave/cli"
+	"golang.org/x/sync/errgroup"
+)
+
+// Log messages start with the stream they were written to
+const (
+	stdoutPrefix = "01 "
+	stderrPrefix = "02 "
+)
+
+func logs(c *cli.Context) error {
+	p, err := getProject(c)
+	if err != nil {
+		return err
+	}
+
+	containers, err := logContainers(p, c.Args())
+	if err != nil {
+		return err
+	}
+
+	input := &client.ContainerLogs{
+		Follow: c.Bool("follow"),
+		Lines:  c.Int64("lines"),
+	}
+
+	var lock sync.Mutex
+	var g errgroup.Group
+	for i := range containers {
+		container := &containers[i]
+		g.Go(func() error {
+			return streamLogs(p.Client, container, input, &lock)
+		})
+	}
+	return g.Wait()
+}
+
+// logContainers returns the containers of the selected services and the
+// selected standalone containers, or every container of the stack
+func logContainers(p *project.Project, selected []string) ([]client.Container, error) {
+	serviceNames := map[string]string{}
+	services, err := p.Client.Service.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"stackId":      p.Stack.Id,
+			"removed_null": nil,
+		},
+	})
+	for services != nil && err == nil {
+		for _, service := range services.Data {
+			serviceNames[service.Id] = service.Name
+		}
+		services, err = services.Next()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []client.Container
+	containers, err := p.Client.Container.List(&client.ListOpts{
+		Filters: map[string]interface{}{
+			"stackId":      p.Stack.Id,
+			"removed_null": nil,
+		},
+	})
+	for containers != nil && err == nil {
+		for _, container := range containers.Data {
+			name := container.Name
+			if container.ServiceId != "" {
+				name = serviceNames[container.ServiceId]
+			}
+			if utils.IsSelected(selected, name) {
+				result = append(result, container)
+			}
+		}
+		containers, err = containers.Next()
+	}
+	return result, err
+}
+
+// streamLogs copies the logs of a container to stdout and stderr, prefixed
+// with the container name
+func streamLogs(c *client.RancherClient, container *client.Container, input *client.ContainerLogs, lock *sync.Mutex) error {
+	hostAccess, err := c.Container.ActionLogs(container, input)
+	if err != nil {
+		return err
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(hostAccess.Url+"?token="+hostAccess.Token, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to connect to the logs of %s: %v", container.Name, err)
+	}
+	defer conn.Close()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		out := os.Stdout
+		text := strings.TrimSuffix(string(message), "\n")
+		switch {
+		case strings.HasPrefix(text, stdoutPrefix):
+			text = strings.TrimPrefix(text, stdoutPrefix)
+		case strings.HasPrefix(text, stderrPrefix):
+			text = strings.TrimPrefix(text, stderrPrefix)
+			out = os.Stderr
+		}
+
+		lock.Lock()
+		fmt.Fprintf(out, "%s | %s\n", container.Name, text)
+		lock.Unlock()
+	}
+}
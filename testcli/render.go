@@ -0,0 +1,117 @@
+package testcli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/lookup"
+	"github.com/rancher/rancher-compose-executor/project"
+	"github.com/rancher/rancher-compose-executor/utils"
+	"github.com/urfave/cli"
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v2"
+)
+
+// render renders the templates offline for every answers file, so that
+// templates can be checked without a Rancher server
+func render(c *cli.Context) error {
+	format := c.String("format")
+	if format != "yaml" && format != "json" {
+		return fmt.Errorf("Invalid format %s, must be yaml or json", format)
+	}
+
+	files, variables, err := getFiles(c)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("No templates found")
+	}
+
+	projectName := c.GlobalString("project-name")
+	if projectName == "" {
+		projectName = "render"
+	}
+	cluster := &client.Cluster{
+		Orchestration: c.String("orchestration"),
+		Embedded:      c.Bool("embedded"),
+	}
+
+	answersFiles := c.Args()
+	if len(answersFiles) == 0 {
+		answersFiles = []string{""}
+	}
+
+	failed := 0
+	for _, answersFile := range answersFiles {
+		name := answersFile
+		if name == "" {
+			name = "templates"
+		}
+
+		if err := renderOne(c, format, projectName, cluster, files, variables, answersFile); err != nil {
+			logrus.Errorf("%s: %v", name, err)
+			failed++
+			continue
+		}
+		logrus.Infof("%s: OK", name)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d renders failed", failed, len(answersFiles))
+	}
+	return nil
+}
+
+func renderOne(c *cli.Context, format, projectName string, cluster *client.Cluster, files, variables map[string]string, answersFile string) error {
+	answers := variables
+	if answersFile != "" {
+		fileAnswers, err := getAnswers(answersFile)
+		if err != nil {
+			return err
+		}
+		answers = utils.MapUnion(variables, fileAnswers)
+	}
+
+	p := project.NewOfflineProject(projectName, cluster)
+	// Rendered templates are catalog content, which must not read files
+	// outside of its directory
+	p.ResourceLookup = &lookup.FileResourceLookup{
+		Root:     projectDir(c),
+		Restrict: true,
+	}
+	if err := p.Parse(context.Background(), files, answers); err != nil {
+		return err
+	}
+	// Converting catches what only fails once the config becomes payloads
+	if _, err := generatePayloads(p); err != nil {
+		return err
+	}
+
+	if c.Bool("quiet") {
+		return nil
+	}
+	return printDocument(format, p.Config, true)
+}
+
+// getAnswers reads a YAML map of answers, or KEY=VALUE lines like an env
+// file
+func getAnswers(filename string) (map[string]string, error) {
+	if !strings.HasSuffix(filename, ".yml") && !strings.HasSuffix(filename, ".yaml") {
+		return getVariables(filename)
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var answers map[string]interface{}
+	if err := yaml.Unmarshal(content, &answers); err != nil {
+		return nil, fmt.Errorf("Failed to parse answers %s: %v", filename, err)
+	}
+	return utils.ToMapString(answers), nil
+}
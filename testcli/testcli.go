@@ -5,6 +5,8 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/rancher-compose-executor/logging"
+	"github.com/rancher/rancher-compose-executor/redact"
 	_ "github.com/rancher/rancher-compose-executor/resources"
 	"github.com/rancher/rancher-compose-executor/version"
 	"github.com/urfave/cli"
@@ -16,24 +18,28 @@ const (
 	rancherSecretKeyEnv = "RANCHER_SECRET_KEY"
 )
 
-var (
-	rancherClient *client.RancherClient
-)
-
 func beforeApp(c *cli.Context) error {
+	if err := logging.SetFormat(c.GlobalString("log-format")); err != nil {
+		return err
+	}
+	redact.RegisterCredentials(os.Getenv(rancherAccessKeyEnv), os.Getenv(rancherSecretKeyEnv))
 	if c.GlobalBool("verbose") {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
+	return nil
+}
+
+// newClient connects to Rancher. Commands that work offline never call it.
+func newClient() (*client.RancherClient, error) {
 	url, err := client.NormalizeUrl(os.Getenv(rancherURLEnv))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	rancherClient, err = client.NewRancherClient(&client.ClientOpts{
+	return client.NewRancherClient(&client.ClientOpts{
 		Url:       url,
 		AccessKey: os.Getenv(rancherAccessKeyEnv),
 		SecretKey: os.Getenv(rancherSecretKeyEnv),
 	})
-	return err
 }
 
 func Main() {
@@ -45,6 +51,11 @@ func Main() {
 		cli.BoolFlag{
 			Name: "verbose,debug",
 		},
+		cli.StringFlag{
+			Name:  "log-format",
+			Usage: "Log as text or json",
+			Value: "text",
+		},
 		cli.StringSliceFlag{
 			Name:   "file,f",
 			Usage:  "Specify one or more alternate compose files (default: docker-compose.yml)",
@@ -64,6 +75,11 @@ func Main() {
 			Name:  "env-file,e",
 			Usage: "Specify a file from which to read environment variables",
 		},
+		cli.StringFlag{
+			Name:   "cluster-id",
+			Usage:  "Specify the cluster to deploy to",
+			EnvVar: "RANCHER_CLUSTER_ID",
+		},
 	}
 	app.Commands = []cli.Command{
 		cli.Command{
@@ -78,6 +94,145 @@ func Main() {
 				return up(c)
 			},
 		},
+		cli.Command{
+			Name:      "rollback",
+			Usage:     "Roll back services and containers to their previous revision",
+			ArgsUsage: "[SERVICE...]",
+			Action: func(c *cli.Context) error {
+				return rollback(c)
+			},
+		},
+		cli.Command{
+			Name:      "history",
+			Usage:     "List the upgrade history of standalone containers",
+			ArgsUsage: "[CONTAINER...]",
+			Action: func(c *cli.Context) error {
+				return history(c)
+			},
+		},
+		cli.Command{
+			Name:      "rm",
+			Usage:     "Remove services and containers, and the stack when none are given",
+			ArgsUsage: "[SERVICE...]",
+			Action: func(c *cli.Context) error {
+				return rm(c)
+			},
+		},
+		cli.Command{
+			Name:  "ps",
+			Usage: "List the services and containers of the stack",
+			Action: func(c *cli.Context) error {
+				return ps(c)
+			},
+		},
+		cli.Command{
+			Name:      "scale",
+			Usage:     "Set the number of containers of services",
+			ArgsUsage: "SERVICE=NUM...",
+			Action: func(c *cli.Context) error {
+				return scale(c)
+			},
+		},
+		cli.Command{
+			Name:      "stop",
+			Usage:     "Stop services and containers",
+			ArgsUsage: "[SERVICE...]",
+			Action: func(c *cli.Context) error {
+				return stop(c)
+			},
+		},
+		cli.Command{
+			Name:      "start",
+			Usage:     "Start services and containers",
+			ArgsUsage: "[SERVICE...]",
+			Action: func(c *cli.Context) error {
+				return start(c)
+			},
+		},
+		cli.Command{
+			Name:      "restart",
+			Usage:     "Restart services and containers",
+			ArgsUsage: "[SERVICE...]",
+			Action: func(c *cli.Context) error {
+				return restart(c)
+			},
+		},
+		cli.Command{
+			Name:  "config",
+			Usage: "Print the merged config of the stack",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "Output format, yaml or json",
+					Value: "yaml",
+				},
+				cli.BoolFlag{
+					Name:  "payloads",
+					Usage: "Also print the services and containers sent to the API",
+				},
+				cli.BoolFlag{
+					Name:  "references",
+					Usage: "Also list the server side resources the payloads refer to",
+				},
+				cli.BoolFlag{
+					Name:  "resolve",
+					Usage: "Resolve server side references through the API of --cluster-id instead of using placeholders",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return printConfig(c)
+			},
+		},
+		cli.Command{
+			Name:      "render",
+			Usage:     "Render and validate the templates without a Rancher server",
+			ArgsUsage: "[ANSWERS_FILE...]",
+			Description: "Renders the templates once for every answers file, or once with the --env-file variables, " +
+				"and converts them to API payloads with placeholder references. Answers files are YAML maps " +
+				"when they end in .yml or .yaml and KEY=VALUE lines otherwise.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "Output format, yaml or json",
+					Value: "yaml",
+				},
+				cli.BoolFlag{
+					Name:  "quiet,q",
+					Usage: "Only validate, don't print the rendered config",
+				},
+				cli.StringFlag{
+					Name:  "orchestration",
+					Usage: "Orchestration of the cluster the templates are rendered for",
+					Value: "cattle",
+				},
+				cli.BoolFlag{
+					Name:  "embedded",
+					Usage: "Render for an embedded cluster",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return render(c)
+			},
+		},
+		cli.Command{
+			Name:      "logs",
+			Usage:     "Show the logs of services and containers",
+			ArgsUsage: "[SERVICE...]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "follow,f",
+					Usage: "Follow log output",
+				},
+				cli.Int64Flag{
+					Name:  "lines,n",
+					Usage: "Number of lines to show from the end of the logs",
+					Value: 100,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return logs(c)
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {